@@ -0,0 +1,187 @@
+package vslparser
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Binary VSL file layout constants, see include/vapi/vsl_int.h in the Varnish
+// source tree.
+const (
+	vslFileID       = "VSL\x00"
+	vslOverhead     = 8 // two 32-bit words
+	vslLenMask      = 0xffff
+	vslVerMask      = 0x3
+	vslVerShift     = 16
+	vslIDShift      = 24
+	vslClientMarker = 1 << 30
+	vslBackMarker   = 1 << 31
+	vslIdentMask    = ^uint32(3 << 30)
+	vslTagBatch     = 255
+)
+
+// vslTagNames maps numeric VSL tag IDs to tag names. The numbering follows
+// include/tbl/vsl_tags.h of Varnish 6.0 and newer, where new tags are only
+// ever appended.
+var vslTagNames = []string{
+	"__Bogus", "Debug", "Error", "CLI", "SessOpen", "SessClose",
+	"BackendOpen", "BackendReuse", "BackendClose", "HttpGarbage", "Proxy",
+	"ProxyGarbage", "BackendStart", "Length", "FetchError",
+	"ReqMethod", "ReqURL", "ReqProtocol", "ReqStatus", "ReqReason",
+	"ReqHeader", "ReqUnset", "ReqLost",
+	"RespMethod", "RespURL", "RespProtocol", "RespStatus", "RespReason",
+	"RespHeader", "RespUnset", "RespLost",
+	"BereqMethod", "BereqURL", "BereqProtocol", "BereqStatus",
+	"BereqReason", "BereqHeader", "BereqUnset", "BereqLost",
+	"BerespMethod", "BerespURL", "BerespProtocol", "BerespStatus",
+	"BerespReason", "BerespHeader", "BerespUnset", "BerespLost",
+	"ObjMethod", "ObjURL", "ObjProtocol", "ObjStatus", "ObjReason",
+	"ObjHeader", "ObjUnset", "ObjLost",
+	"BogoHeader", "LostHeader", "TTL", "Fetch_Body", "VCL_acl",
+	"VCL_call", "VCL_trace", "VCL_return", "ReqStart", "Hit", "HitPass",
+	"ExpBan", "ExpKill", "WorkThread", "ESI_xmlerror", "Hash",
+	"Backend_health", "VCL_Log", "VCL_Error", "Gzip", "Link", "Begin",
+	"End", "VSL", "Storage", "Timestamp", "ReqAcct", "PipeAcct",
+	"BereqAcct", "VfpAcct", "Witness", "H2RxHdr", "H2RxBody", "H2TxHdr",
+	"H2TxBody", "HitMiss", "Filters", "SessError", "VCL_use", "Notice",
+	"VdpAcct",
+}
+
+// BinaryParser implements parsing of binary VSL files, such as those written
+// by "varnishlog -w". It produces the same Entry values as EntryParser does
+// for the text output of "varnishlog" reading the same file.
+//
+// Records are expected in the native byte order of the machine which wrote
+// them, little-endian is assumed.
+type BinaryParser struct {
+	r          *bufio.Reader
+	tagNames   []string
+	headerRead bool
+	grouper    recordGrouper
+	buf        []byte
+}
+
+// NewBinaryParser creates a new BinaryParser reading & parsing r.
+func NewBinaryParser(r io.Reader) *BinaryParser {
+	return &BinaryParser{
+		r:        bufio.NewReader(r),
+		tagNames: vslTagNames,
+	}
+}
+
+// SetTagNames overrides the table translating numeric tag IDs to tag names.
+// Use it to read files written by Varnish versions with a different tag
+// numbering. Tag IDs without a name are reported as "Tag<ID>".
+func (p *BinaryParser) SetTagNames(names []string) {
+	p.tagNames = names
+}
+
+// ParseRecord reads a single record from the file. Records of different
+// transactions are returned in the order they were written, i.e. possibly
+// interleaved.
+func (p *BinaryParser) ParseRecord() (Record, error) {
+	if !p.headerRead {
+		if err := p.readHeader(); err != nil {
+			return Record{}, err
+		}
+		p.headerRead = true
+	}
+
+	var hdr [vslOverhead]byte
+	for {
+		if _, err := io.ReadFull(p.r, hdr[:]); err != nil {
+			return Record{}, err
+		}
+
+		w0 := binary.LittleEndian.Uint32(hdr[0:4])
+		w1 := binary.LittleEndian.Uint32(hdr[4:8])
+
+		tag := int(w0 >> vslIDShift)
+		if tag == vslTagBatch {
+			// Batch header only wraps the records which follow it.
+			continue
+		}
+		if ver := (w0 >> vslVerShift) & vslVerMask; ver != 0 {
+			return Record{}, fmt.Errorf("unsupported VSL record version %d", ver)
+		}
+
+		length := int(w0 & vslLenMask)
+		padded := (length + 3) &^ 3
+		if cap(p.buf) < padded {
+			p.buf = make([]byte, padded)
+		}
+		payload := p.buf[:padded]
+		if _, err := io.ReadFull(p.r, payload); err != nil {
+			return Record{}, unexpectedEOF(err)
+		}
+		payload = payload[:length]
+		if length > 0 && payload[length-1] == 0 {
+			payload = payload[:length-1]
+		}
+
+		rec := Record{
+			VXID:   VXID(w1 & vslIdentMask),
+			Marker: MarkerNone,
+			Tag: Tag{
+				Key:   p.tagName(tag),
+				Value: strings.TrimLeft(string(payload), " \t\n"),
+			},
+		}
+		switch {
+		case w1&vslClientMarker != 0:
+			rec.Marker = MarkerClient
+		case w1&vslBackMarker != 0:
+			rec.Marker = MarkerBackend
+		}
+		return rec, nil
+	}
+}
+
+// Parse reads records until some transaction is complete and returns it as an
+// Entry. Non-transactional records are skipped. Transactions which are not
+// complete at the end of the file are dropped and io.EOF is returned.
+func (p *BinaryParser) Parse() (Entry, error) {
+	for {
+		rec, err := p.ParseRecord()
+		if err != nil {
+			return Entry{}, err
+		}
+		if e, ok := p.grouper.add(rec); ok {
+			return e, nil
+		}
+	}
+}
+
+func (p *BinaryParser) readHeader() error {
+	// Like the text parsers, return io.EOF for empty input. A partial
+	// header is reported as io.ErrUnexpectedEOF by io.ReadFull.
+	var id [len(vslFileID)]byte
+	if _, err := io.ReadFull(p.r, id[:]); err != nil {
+		return err
+	}
+	if string(id[:]) != vslFileID {
+		return fmt.Errorf("not a VSL file: bad file header %q", id[:])
+	}
+	return nil
+}
+
+func (p *BinaryParser) tagName(tag int) string {
+	if tag < len(p.tagNames) && p.tagNames[tag] != "" {
+		return p.tagNames[tag]
+	}
+	return "Tag" + strconv.Itoa(tag)
+}
+
+// unexpectedEOF converts io.EOF to io.ErrUnexpectedEOF, so that callers can
+// tell apart truncated input from the clean end of the file.
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,157 @@
+package vslparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeVSLRecord writes a single binary VSL record to buf.
+func writeVSLRecord(t testing.TB, buf *bytes.Buffer, vxid VXID, marker byte, tag Tag) {
+	id := -1
+	for i, name := range vslTagNames {
+		if name == tag.Key {
+			id = i
+			break
+		}
+	}
+	require.NotEqual(t, -1, id, "unknown tag %q", tag.Key)
+
+	payload := append([]byte(tag.Value), 0)
+	w1 := uint32(vxid)
+	switch marker {
+	case MarkerClient:
+		w1 |= vslClientMarker
+	case MarkerBackend:
+		w1 |= vslBackMarker
+	}
+
+	var hdr [vslOverhead]byte
+	binary.LittleEndian.PutUint32(hdr[0:4], uint32(id)<<vslIDShift|uint32(len(payload)))
+	binary.LittleEndian.PutUint32(hdr[4:8], w1)
+	buf.Write(hdr[:])
+	buf.Write(payload)
+	buf.Write(make([]byte, (len(payload)+3)&^3-len(payload)))
+}
+
+func TestBinaryParser_Parse(t *testing.T) {
+	r := require.New(t)
+
+	file, err := os.Open("testdata/varnishlog_request.txt")
+	r.NoError(err)
+	defer file.Close()
+
+	group, err := NewRequestParser(file).Parse()
+	r.NoError(err)
+	r.Len(group, 2)
+	req, bereq := group[0], group[1]
+
+	// Interleave records of both transactions with a non-transactional
+	// record, just like Varnish writes them into the shared memory log.
+	var buf bytes.Buffer
+	buf.WriteString(vslFileID)
+	writeVSLRecord(t, &buf, 0, MarkerNone, Tag{"CLI", "Rd ping"})
+	for i, tag := range req.Tags {
+		writeVSLRecord(t, &buf, req.VXID, MarkerClient, tag)
+		if i < len(bereq.Tags) {
+			writeVSLRecord(t, &buf, bereq.VXID, MarkerBackend, bereq.Tags[i])
+		}
+	}
+
+	parser := NewBinaryParser(&buf)
+
+	bereq.Level = 1
+	got, err := parser.Parse()
+	r.NoError(err)
+	r.Equal(bereq, got)
+
+	got, err = parser.Parse()
+	r.NoError(err)
+	r.Equal(req, got)
+
+	_, err = parser.Parse()
+	r.Equal(io.EOF, err)
+}
+
+func TestBinaryParser_ParseRecord(t *testing.T) {
+	r := require.New(t)
+
+	var buf bytes.Buffer
+	buf.WriteString(vslFileID)
+	writeVSLRecord(t, &buf, 0, MarkerNone, Tag{"Backend_health", "boot.default Still healthy"})
+	writeVSLRecord(t, &buf, 5, MarkerBackend, Tag{"Length", "278"})
+	full := buf.Bytes()
+
+	parser := NewBinaryParser(bytes.NewReader(full))
+	rec, err := parser.ParseRecord()
+	r.NoError(err)
+	r.Equal(Record{
+		VXID:   0,
+		Marker: MarkerNone,
+		Tag:    Tag{"Backend_health", "boot.default Still healthy"},
+	}, rec)
+
+	rec, err = parser.ParseRecord()
+	r.NoError(err)
+	r.Equal(Record{VXID: 5, Marker: MarkerBackend, Tag: Tag{"Length", "278"}}, rec)
+
+	_, err = parser.ParseRecord()
+	r.Equal(io.EOF, err)
+
+	// Truncated payload.
+	_, err = NewBinaryParser(bytes.NewReader(full[:len(full)-2])).Parse()
+	r.ErrorIs(err, io.ErrUnexpectedEOF)
+
+	// Empty input and truncated file header.
+	_, err = NewBinaryParser(bytes.NewReader(nil)).Parse()
+	r.Equal(io.EOF, err)
+	_, err = NewBinaryParser(bytes.NewReader(full[:2])).Parse()
+	r.ErrorIs(err, io.ErrUnexpectedEOF)
+
+	// Not a VSL file.
+	_, err = NewBinaryParser(bytes.NewReader([]byte("*   << Request  >> 2"))).Parse()
+	r.Error(err)
+}
+
+// TestBinaryParser_Capture checks the parser against a file written by real
+// varnishlog -w, which also verifies the tag numbering. The capture is
+// generated by `testdata/run_docker.sh varnish capture`.
+func TestBinaryParser_Capture(t *testing.T) {
+	r := require.New(t)
+
+	file, err := os.Open("testdata/capture/varnishlog.bin")
+	r.NoError(err)
+	defer file.Close()
+
+	text, err := os.Open("testdata/capture/varnishlog_vxid.txt")
+	r.NoError(err)
+	defer text.Close()
+
+	var want []Entry
+	tp := NewEntryParser(text)
+	for {
+		e, err := tp.Parse()
+		if err == io.EOF {
+			break
+		}
+		r.NoError(err)
+		want = append(want, e)
+	}
+	r.NotEmpty(want)
+
+	var got []Entry
+	bp := NewBinaryParser(file)
+	for {
+		e, err := bp.Parse()
+		if err == io.EOF {
+			break
+		}
+		r.NoError(err)
+		got = append(got, e)
+	}
+	r.Equal(want, got)
+}
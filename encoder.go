@@ -0,0 +1,70 @@
+package vslparser
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Encoder writes entries in the text format of varnishlog, i.e. the format
+// read by EntryParser, RequestParser and SessionParser.
+//
+// Entries are written with the column alignment of varnishlog, so logs can be
+// filtered or redacted and re-emitted in a form which is indistinguishable from
+// the original. Note that leading white space of tag values and line breaks
+// within them cannot be represented in the format.
+type Encoder struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+// NewEncoder creates a new Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes a single entry followed by an empty line, like varnishlog
+// does with vxid grouping.
+func (enc *Encoder) Encode(e Entry) error {
+	return enc.EncodeGroup([]Entry{e})
+}
+
+// EncodeGroup writes entries of a request or session group followed by an
+// empty line, which is the group delimiter.
+func (enc *Encoder) EncodeGroup(entries []Entry) error {
+	enc.buf.Reset()
+	for _, e := range entries {
+		writeEntry(&enc.buf, e)
+	}
+	enc.buf.WriteByte('\n')
+
+	_, err := enc.w.Write(enc.buf.Bytes())
+	return err
+}
+
+// writeEntry writes the header line and tag lines of e, e.g.:
+// *   << Request  >> 32770
+// -   Begin          req 32769 rxreq
+func writeEntry(buf *bytes.Buffer, e Entry) {
+	buf.WriteString(levelPrefix(e.Level, '*'))
+	fmt.Fprintf(buf, "<< %-8s >> %-10d\n", e.Kind, e.VXID)
+
+	prefix := levelPrefix(e.Level, '-')
+	for _, tag := range e.Tags {
+		buf.WriteString(prefix)
+		fmt.Fprintf(buf, "%-14s %s\n", tag.Key, tag.Value)
+	}
+}
+
+// levelPrefix returns the indentation of lines of an entry of the given level,
+// e.g. "**  " or "-4- ".
+func levelPrefix(level int, c byte) string {
+	if level > 3 {
+		return fmt.Sprintf("%c%d%c ", c, level, c)
+	}
+	if level < 0 {
+		level = 0
+	}
+	return fmt.Sprintf("%-3s ", strings.Repeat(string(c), level))
+}
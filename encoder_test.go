@@ -0,0 +1,69 @@
+package vslparser
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncoder_RoundTrip(t *testing.T) {
+	r := require.New(t)
+
+	data, err := os.ReadFile("testdata/varnishlog_request.txt")
+	r.NoError(err)
+
+	var groups [][]Entry
+	p := NewRequestParser(bytes.NewReader(data))
+	for {
+		entries, err := p.Parse()
+		if err == io.EOF {
+			break
+		}
+		r.NoError(err)
+		groups = append(groups, entries)
+	}
+	r.Len(groups, 3)
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, g := range groups {
+		r.NoError(enc.EncodeGroup(g))
+	}
+	r.Equal(string(data), buf.String())
+}
+
+func TestEncoder_DeepLevels(t *testing.T) {
+	r := require.New(t)
+
+	var entries []Entry
+	for level := 1; level <= 5; level++ {
+		entries = append(entries, Entry{
+			Level: level,
+			Kind:  KindRequest,
+			VXID:  VXID(level),
+			Tags: []Tag{
+				{Key: "Begin", Value: "req 1 esi"},
+				{Key: "ReqHeader", Value: "X-Long-Header-Name-Beyond-Column:  two  spaces "},
+				{Key: "VeryLongTagNameOverflowing", Value: "v"},
+				{Key: "End", Value: ""},
+			},
+		})
+	}
+
+	var buf bytes.Buffer
+	r.NoError(NewEncoder(&buf).EncodeGroup(entries))
+	r.Contains(buf.String(), "*5* << Request  >> 5         \n-5- Begin          req 1 esi\n")
+
+	got, err := NewRequestParser(&buf).Parse()
+	r.NoError(err)
+	r.Equal(entries, got)
+
+	buf.Reset()
+	r.NoError(NewEncoder(&buf).Encode(entries[3]))
+	e, err := NewEntryParser(&buf).Parse()
+	r.NoError(err)
+	r.Equal(entries[3], e)
+}
@@ -15,10 +15,18 @@ type VXID uint32
 // Entry holds a single log entry. An entry consists mostly of a collection of
 // log fields, called Tags.
 type Entry struct {
+	// Level is the nesting level of the entry within a group, 1 for the top
+	// level. varnishlog marks levels up to 3 by asterisks and dashes, e.g.
+	// "***" and "---", and deeper levels by the number, e.g. "*4*" and
+	// "-4-". The parsers accept both forms.
 	Level int
 	Kind  string
 	VXID  VXID
 	Tags  []Tag
+
+	// Truncated holds indices of Tags whose values were truncated, because
+	// their lines exceeded the maximum line size. See TruncateLongLines.
+	Truncated []int
 }
 
 // Tag is the key/value pair making up a VSL tag.
@@ -0,0 +1,46 @@
+// Package testlog gives tests of the subpackages access to the shared test
+// logs in the testdata directory of the repository.
+package testlog
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/Showmax/vslparser"
+)
+
+// Path returns path of the named file in the testdata directory.
+func Path(name string) string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "testdata", name)
+}
+
+// RequestGroups returns all request groups of varnishlog_request.txt. It
+// fails the test if the log cannot be read or parsed.
+func RequestGroups(t testing.TB) [][]vslparser.Entry {
+	t.Helper()
+
+	file, err := os.Open(Path("varnishlog_request.txt"))
+	if err != nil {
+		t.Fatalf("cannot open test data: %v", err)
+	}
+	defer file.Close()
+
+	var groups [][]vslparser.Entry
+	p := vslparser.NewRequestParser(file)
+	for {
+		entries, err := p.Parse()
+		if err == io.EOF {
+			return groups
+		}
+		if err != nil {
+			t.Fatalf("cannot parse test data: %v", err)
+		}
+		if len(entries) > 0 {
+			groups = append(groups, entries)
+		}
+	}
+}
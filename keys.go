@@ -15,17 +15,54 @@ const (
 	TagBegin = "Begin"
 	// TagEnd is tag key informing about end of tags section (end of VXID).
 	TagEnd = "End"
+	// TagLink is a tag key linking the transaction to a child transaction
+	// it initiated.
+	TagLink = "Link"
 	// TagVSL is a tag key identifying any VSL API warning or error.
 	TagVSL = "VSL"
 
+	// TagSessOpen is a tag key identifying start of a client connection,
+	// it holds the socket endpoints.
+	TagSessOpen = "SessOpen"
+	// TagSessClose is a tag key identifying end of a client connection.
+	TagSessClose = "SessClose"
+	// TagProxy is a tag key identifying PROXY protocol information of
+	// a client connection.
+	TagProxy = "Proxy"
+
+	// TagReqStart is a tag key identifying start of request processing,
+	// it holds client address.
+	TagReqStart = "ReqStart"
 	// TagReqURL is a tag key identifying request URL.
 	TagReqURL = "ReqURL"
 	// TagReqProtocol is a tag key identifying HTTP protocol version.
 	TagReqProtocol = "ReqProtocol"
 	// TagReqMethod is a tag key identifying HTTP request method verb.
 	TagReqMethod = "ReqMethod"
+	// TagRespProtocol is a tag key identifying HTTP response protocol
+	// version.
+	TagRespProtocol = "RespProtocol"
 	// TagRespStatus is a tag key identifying HTTP response status code.
 	TagRespStatus = "RespStatus"
+	// TagRespReason is a tag key identifying HTTP response reason phrase.
+	TagRespReason = "RespReason"
+	// TagReqAcct is a tag key identifying byte counts of a client request.
+	TagReqAcct = "ReqAcct"
+
+	// TagBeReqURL is a tag key identifying BeReq URL.
+	TagBeReqURL = "BereqURL"
+	// TagBeReqProtocol is a tag key identifying BeReq HTTP protocol
+	// version.
+	TagBeReqProtocol = "BereqProtocol"
+	// TagBeReqMethod is a tag key identifying BeReq HTTP method verb.
+	TagBeReqMethod = "BereqMethod"
+	// TagBeRespProtocol is a tag key identifying BeResp HTTP protocol
+	// version.
+	TagBeRespProtocol = "BerespProtocol"
+	// TagBeRespReason is a tag key identifying BeResp HTTP reason phrase.
+	TagBeRespReason = "BerespReason"
+	// TagBeReqAcct is a tag key identifying byte counts of a BeReq.
+	TagBeReqAcct = "BereqAcct"
 
 	// TagReqHeader is a tag indicating that Request header was set.
 	TagReqHeader = "ReqHeader"
@@ -47,6 +84,17 @@ const (
 	// TagBackendOpen is a tag indicating that backend connection has been
 	// open.
 	TagBackendOpen = "BackendOpen"
+	// TagBackendReuse is a tag indicating that backend connection has been
+	// put up for reuse.
+	TagBackendReuse = "BackendReuse"
+	// TagBackendClose is a tag indicating that backend connection has been
+	// closed or recycled.
+	TagBackendClose = "BackendClose"
+	// TagBackendStart is a tag indicating start of backend processing.
+	TagBackendStart = "BackendStart"
+	// TagBackendHealth is a tag informing about result of a backend health
+	// probe.
+	TagBackendHealth = "Backend_health"
 	// TagFetchError is a tag informing about reason of backend fetch
 	// operation failure.
 	TagFetchError = "FetchError"
@@ -54,6 +102,26 @@ const (
 	// status code.
 	TagBerespStatus = "BerespStatus"
 
+	// TagVCLCall is a tag informing about VCL subroutine being called.
+	TagVCLCall = "VCL_call"
+	// TagVCLReturn is a tag informing about VCL subroutine return action.
+	TagVCLReturn = "VCL_return"
+	// TagVCLLog is a tag containing a message logged by std.log() in VCL.
+	TagVCLLog = "VCL_Log"
+	// TagVCLError is a tag informing about an error during VCL execution.
+	TagVCLError = "VCL_Error"
+	// TagVCLACL is a tag informing about result of a VCL ACL match.
+	TagVCLACL = "VCL_acl"
+
+	// TagHit is a tag informing that an object was found in cache.
+	TagHit = "Hit"
+	// TagHitMiss is a tag informing that a hit-for-miss object was found
+	// in cache.
+	TagHitMiss = "HitMiss"
+	// TagHitPass is a tag informing that a hit-for-pass object was found
+	// in cache.
+	TagHitPass = "HitPass"
+
 	// TagTimestamp is a tag containing timing information for the Varnish
 	// worker thread.
 	TagTimestamp = "Timestamp"
@@ -86,6 +154,33 @@ const (
 	// TimestampReqEventRestart  is a Request-level timestamp which
 	// identifies timestamp of request processing restart.
 	TimestampReqEventRestart = "Restart"
+	// TimestampReqEventPipe is a Request-level timestamp which identifies
+	// timestamp when the request was handed over to pipe mode.
+	TimestampReqEventPipe = "Pipe"
+	// TimestampReqEventPipeSess is a Request-level timestamp which
+	// identifies timestamp when the pipe session finished.
+	TimestampReqEventPipeSess = "PipeSess"
+)
+
+const (
+	// TimestampBeReqEventStart is a BeReq-level timestamp which identifies
+	// timestamp of backend fetch start.
+	TimestampBeReqEventStart = "Start"
+	// TimestampBeReqEventBereq is a BeReq-level timestamp which identifies
+	// timestamp when the backend request was sent.
+	TimestampBeReqEventBereq = "Bereq"
+	// TimestampBeReqEventBeresp is a BeReq-level timestamp which identifies
+	// timestamp when the backend response headers were received.
+	TimestampBeReqEventBeresp = "Beresp"
+	// TimestampBeReqEventBerespBody is a BeReq-level timestamp which
+	// identifies timestamp when the backend response body was received.
+	TimestampBeReqEventBerespBody = "BerespBody"
+	// TimestampBeReqEventRetry is a BeReq-level timestamp which identifies
+	// timestamp of backend fetch retry.
+	TimestampBeReqEventRetry = "Retry"
+	// TimestampBeReqEventError is a BeReq-level timestamp which identifies
+	// timestamp of backend fetch failure.
+	TimestampBeReqEventError = "Error"
 )
 
 // https://book.varnish-software.com/4.0/chapters/Examining_Varnish_Server_s_Output.html#transactions
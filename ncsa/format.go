@@ -0,0 +1,580 @@
+// Package ncsa renders entries parsed by vslparser as access log lines in the
+// format of varnishncsa and parses such lines back into entries.
+//
+// Format strings use the directives of varnishncsa:
+//
+//	%b   size of response body in bytes, '-' rather than 0
+//	%D   time taken to serve the request, in microseconds
+//	%H   request protocol
+//	%h   remote host
+//	%I   total bytes received
+//	%{X}i  contents of request header X
+//	%l   remote logname, always '-'
+//	%m   request method
+//	%{X}o  contents of response header X
+//	%O   total bytes sent
+//	%q   query string including the leading '?', empty if there is none
+//	%r   first line of the request, "%m http://%{Host}i%U%q %H"
+//	%s   response status
+//	%t   time the request was received, "[%d/%b/%Y:%T %z]"
+//	%{X}t  time the request was received in strftime(3) format X
+//	%T   time taken to serve the request, in seconds
+//	%U   request URL without the query string
+//	%u   remote user from basic authentication
+//	%{Varnish:X}x  Varnish specific data, X is one of handling, hitmiss,
+//	     side, time_firstbyte and vxid
+//	%{VSL:Tag:Prefix[Field]}x  value of a tag, the prefix and the field
+//	     (counted from 1) are optional
+//	%%   literal '%'
+//
+// Like varnishncsa, missing values are rendered as '-' and tags and headers
+// logged several times are taken from their first occurrence, i.e. requests
+// as received from the client, except for the response status which is taken
+// from its last occurrence, i.e. as sent. Request entries are rendered from
+// the client side, BeReq entries from the backend side.
+package ncsa
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Showmax/vslparser"
+)
+
+// DefaultFormat is the default format of varnishncsa, i.e. the NCSA combined
+// log format.
+const DefaultFormat = `%h %l %u %t "%r" %s %b "%{Referer}i" "%{User-agent}i"`
+
+// directive renders a single format directive of a record.
+type directive func(r *record) string
+
+// Formatter renders entries according to a varnishncsa format string.
+type Formatter struct {
+	// Location is the time zone of %t, time.Local is used if nil.
+	Location *time.Location
+
+	parts []directive
+}
+
+// NewFormatter compiles a varnishncsa format string.
+func NewFormatter(format string) (*Formatter, error) {
+	tokens, err := tokenize(format)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Formatter{}
+	for _, t := range tokens {
+		if t.verb == 0 {
+			literal := t.literal
+			f.parts = append(f.parts, func(*record) string { return literal })
+			continue
+		}
+		d, err := compileDirective(t.verb, t.arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid format %q: %w", format, err)
+		}
+		f.parts = append(f.parts, d)
+	}
+	return f, nil
+}
+
+// MustNewFormatter is like NewFormatter but panics if the format is invalid.
+func MustNewFormatter(format string) *Formatter {
+	f, err := NewFormatter(format)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// Format renders a single Request or BeReq entry.
+func (f *Formatter) Format(e vslparser.Entry) string {
+	r := &record{
+		entry:    e,
+		backend:  e.Kind == vslparser.KindBeReq,
+		location: f.Location,
+	}
+	if r.location == nil {
+		r.location = time.Local
+	}
+
+	var b strings.Builder
+	for _, d := range f.parts {
+		b.WriteString(d(r))
+	}
+	return b.String()
+}
+
+// FormatGroup writes a line for each client request of a group, like
+// varnishncsa does by default. BeReqs, ESI subrequests and requests which
+// were restarted are skipped, so a restarted request is logged once, by its
+// last restart.
+func (f *Formatter) FormatGroup(w io.Writer, group []vslparser.Entry) error {
+	for _, e := range group {
+		if e.Kind != vslparser.KindRequest || restarted(e) {
+			continue
+		}
+		if begin, ok := vslparser.Tags(e.Tags).FirstWithKey(vslparser.TagBegin); ok &&
+			strings.HasSuffix(begin.Value, " "+vslparser.ReasonESI) {
+			continue
+		}
+		if _, err := io.WriteString(w, f.Format(e)+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restarted reports whether VCL restarted request e, which varnishncsa
+// recognizes by VCL_return restart.
+func restarted(e vslparser.Entry) bool {
+	for _, tag := range e.Tags {
+		if tag.Key == vslparser.TagVCLReturn && tag.Value == "restart" {
+			return true
+		}
+	}
+	return false
+}
+
+// token is a piece of a format string, either a literal or a directive.
+type token struct {
+	literal string
+	// verb is the directive character, e.g. 'h' or 'i', 0 for literals.
+	verb byte
+	// arg is the argument in braces, e.g. "Referer" of %{Referer}i.
+	arg string
+}
+
+// tokenize splits a format string into literals and directives. Adjacent
+// literals, including escaped '%', are merged.
+func tokenize(format string) ([]token, error) {
+	var (
+		tokens  []token
+		literal strings.Builder
+	)
+	flush := func() {
+		if literal.Len() > 0 {
+			tokens = append(tokens, token{literal: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			literal.WriteByte(format[i])
+			continue
+		}
+		i++
+		if i == len(format) {
+			return nil, fmt.Errorf("format %q ends with '%%'", format)
+		}
+
+		var arg string
+		if format[i] == '{' {
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated '{' in format %q", format)
+			}
+			arg = format[i+1 : i+end]
+			i += end + 1
+			if i == len(format) {
+				return nil, fmt.Errorf("missing directive after %%{%s} in format %q", arg, format)
+			}
+		}
+
+		if format[i] == '%' && arg == "" {
+			literal.WriteByte('%')
+			continue
+		}
+		flush()
+		tokens = append(tokens, token{verb: format[i], arg: arg})
+	}
+	flush()
+
+	return tokens, nil
+}
+
+func compileDirective(c byte, arg string) (directive, error) {
+	switch c {
+	case 'b':
+		return func(r *record) string {
+			v := r.acct(5, 5)
+			if v == "0" {
+				return "-"
+			}
+			return v
+		}, nil
+	case 'D':
+		return func(r *record) string { return r.duration(1e6) }, nil
+	case 'H':
+		return func(r *record) string { return r.protocol() }, nil
+	case 'h':
+		return func(r *record) string { return r.host() }, nil
+	case 'I':
+		return func(r *record) string { return r.acct(3, 6) }, nil
+	case 'i':
+		if arg == "" {
+			return nil, fmt.Errorf("%%i requires a header name")
+		}
+		return func(r *record) string { return r.reqHeader(arg) }, nil
+	case 'l':
+		return func(*record) string { return "-" }, nil
+	case 'm':
+		return func(r *record) string { return r.method() }, nil
+	case 'o':
+		if arg == "" {
+			return nil, fmt.Errorf("%%o requires a header name")
+		}
+		return func(r *record) string { return r.respHeader(arg) }, nil
+	case 'O':
+		return func(r *record) string { return r.acct(6, 3) }, nil
+	case 'q':
+		return func(r *record) string {
+			_, query := r.url()
+			return query
+		}, nil
+	case 'r':
+		return func(r *record) string {
+			host := r.reqHeader("Host")
+			if host == "-" {
+				host = "localhost"
+			}
+			path, query := r.url()
+			return r.method() + " http://" + host + path + query + " " + r.protocol()
+		}, nil
+	case 's':
+		return func(r *record) string { return r.last(r.key(vslparser.TagRespStatus, vslparser.TagBerespStatus)) }, nil
+	case 't':
+		layout := arg
+		if layout == "" {
+			layout = "[%d/%b/%Y:%T %z]"
+		}
+		return func(r *record) string {
+			t, ok := r.start()
+			if !ok {
+				return "-"
+			}
+			return strftime(layout, t.In(r.location))
+		}, nil
+	case 'T':
+		return func(r *record) string { return r.duration(1) }, nil
+	case 'U':
+		return func(r *record) string {
+			path, _ := r.url()
+			return path
+		}, nil
+	case 'u':
+		return func(r *record) string { return r.user() }, nil
+	case 'x':
+		return compileExtended(arg)
+	}
+	return nil, fmt.Errorf("unknown directive %%%c", c)
+}
+
+func compileExtended(arg string) (directive, error) {
+	switch arg {
+	case "Varnish:handling":
+		return func(r *record) string { return r.handling() }, nil
+	case "Varnish:hitmiss":
+		return func(r *record) string {
+			// Like varnishncsa, anything but a hit is reported as a miss.
+			switch r.handling() {
+			case "hit":
+				return "hit"
+			case "miss", "pass", "pipe", "synth":
+				return "miss"
+			}
+			return "-"
+		}, nil
+	case "Varnish:side":
+		return func(r *record) string {
+			if r.backend {
+				return "b"
+			}
+			return "c"
+		}, nil
+	case "Varnish:time_firstbyte":
+		return func(r *record) string {
+			event := vslparser.TimestampReqEventProcess
+			if r.backend {
+				event = vslparser.TimestampBeReqEventBeresp
+			}
+			return r.timestamp(event, 2)
+		}, nil
+	case "Varnish:vxid":
+		return func(r *record) string { return strconv.FormatUint(uint64(r.entry.VXID), 10) }, nil
+	}
+
+	if strings.HasPrefix(arg, "VSL:") {
+		q, err := parseVSLQuery(arg[len("VSL:"):])
+		if err != nil {
+			return nil, err
+		}
+		return func(r *record) string { return q.value(r.entry) }, nil
+	}
+	return nil, fmt.Errorf("unknown extended variable %q", arg)
+}
+
+// vslQuery selects a tag value for %{VSL:Tag:Prefix[Field]}x.
+type vslQuery struct {
+	tag, prefix string
+	// field counted from 1, 0 means the whole value.
+	field int
+}
+
+func parseVSLQuery(s string) (vslQuery, error) {
+	var q vslQuery
+	if i := strings.IndexByte(s, '['); i >= 0 {
+		if !strings.HasSuffix(s, "]") {
+			return vslQuery{}, fmt.Errorf("unterminated field in %q", s)
+		}
+		field, err := strconv.Atoi(s[i+1 : len(s)-1])
+		if err != nil || field < 0 {
+			return vslQuery{}, fmt.Errorf("invalid field in %q", s)
+		}
+		q.field = field
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		q.prefix = s[i+1:]
+		s = s[:i]
+	}
+	if s == "" {
+		return vslQuery{}, fmt.Errorf("missing tag in VSL variable")
+	}
+	q.tag = s
+	return q, nil
+}
+
+func (q vslQuery) value(e vslparser.Entry) string {
+	for _, tag := range e.Tags {
+		if !strings.EqualFold(tag.Key, q.tag) {
+			continue
+		}
+		v := tag.Value
+		if q.prefix != "" {
+			if len(v) <= len(q.prefix) || !strings.EqualFold(v[:len(q.prefix)], q.prefix) || v[len(q.prefix)] != ':' {
+				continue
+			}
+			v = strings.TrimLeft(v[len(q.prefix)+1:], " ")
+		}
+		if q.field > 0 {
+			fields := strings.Fields(v)
+			if q.field > len(fields) {
+				return "-"
+			}
+			v = fields[q.field-1]
+		}
+		if v == "" {
+			return "-"
+		}
+		return v
+	}
+	return "-"
+}
+
+// record gives access to data of a single entry.
+type record struct {
+	entry    vslparser.Entry
+	backend  bool
+	location *time.Location
+}
+
+// key returns tag key of the client or the backend side.
+func (r *record) key(client, backend string) string {
+	if r.backend {
+		return backend
+	}
+	return client
+}
+
+func (r *record) first(key string) string {
+	if tag, ok := vslparser.Tags(r.entry.Tags).FirstWithKey(key); ok && tag.Value != "" {
+		return tag.Value
+	}
+	return "-"
+}
+
+func (r *record) last(key string) string {
+	if tag, ok := vslparser.Tags(r.entry.Tags).LastWithKey(key); ok && tag.Value != "" {
+		return tag.Value
+	}
+	return "-"
+}
+
+// header returns the first value of header name logged by key.
+func (r *record) header(key, name string) string {
+	for _, tag := range r.entry.Tags {
+		if tag.Key != key {
+			continue
+		}
+		i := strings.IndexByte(tag.Value, ':')
+		if i < 0 || !strings.EqualFold(tag.Value[:i], name) {
+			continue
+		}
+		if v := strings.TrimLeft(tag.Value[i+1:], " \t"); v != "" {
+			return v
+		}
+		return "-"
+	}
+	return "-"
+}
+
+func (r *record) method() string {
+	return r.first(r.key(vslparser.TagReqMethod, vslparser.TagBeReqMethod))
+}
+
+func (r *record) protocol() string {
+	return r.first(r.key(vslparser.TagReqProtocol, vslparser.TagBeReqProtocol))
+}
+
+func (r *record) reqHeader(name string) string {
+	return r.header(r.key(vslparser.TagReqHeader, vslparser.TagBeReqHeader), name)
+}
+
+func (r *record) respHeader(name string) string {
+	return r.header(r.key(vslparser.TagRespHeader, vslparser.TagBeRespHeader), name)
+}
+
+// url returns the path and the query string including the leading '?'.
+func (r *record) url() (path, query string) {
+	u := r.first(r.key(vslparser.TagReqURL, vslparser.TagBeReqURL))
+	if i := strings.IndexByte(u, '?'); i >= 0 {
+		return u[:i], u[i:]
+	}
+	return u, ""
+}
+
+// acct returns a column of ReqAcct (client) or BereqAcct (backend), counted
+// from 1.
+func (r *record) acct(client, backend int) string {
+	key, field := vslparser.TagReqAcct, client
+	if r.backend {
+		key, field = vslparser.TagBeReqAcct, backend
+	}
+	tag, ok := vslparser.Tags(r.entry.Tags).LastWithKey(key)
+	if !ok {
+		return "-"
+	}
+	fields := strings.Fields(tag.Value)
+	if field > len(fields) {
+		return "-"
+	}
+	return fields[field-1]
+}
+
+func (r *record) host() string {
+	if r.backend {
+		tag, ok := vslparser.Tags(r.entry.Tags).LastWithKey(vslparser.TagBackendOpen)
+		if fields := strings.Fields(tag.Value); ok && len(fields) >= 3 {
+			return fields[2]
+		}
+		return "-"
+	}
+	tag, ok := vslparser.Tags(r.entry.Tags).FirstWithKey(vslparser.TagReqStart)
+	if fields := strings.Fields(tag.Value); ok && len(fields) >= 1 {
+		return fields[0]
+	}
+	return "-"
+}
+
+// user returns the user name of basic authentication.
+func (r *record) user() string {
+	auth := r.reqHeader("Authorization")
+	const prefix = "basic "
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "-"
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(auth[len(prefix):]))
+	if err != nil {
+		return "-"
+	}
+	user := string(b)
+	if i := strings.IndexByte(user, ':'); i >= 0 {
+		user = user[:i]
+	}
+	if user == "" {
+		return "-"
+	}
+	return user
+}
+
+// timestamp returns a field of the first Timestamp tag of event, counted from
+// 1 after the event name.
+func (r *record) timestamp(event string, field int) string {
+	for _, tag := range r.entry.Tags {
+		if tag.Key != vslparser.TagTimestamp || !strings.HasPrefix(tag.Value, event+": ") {
+			continue
+		}
+		fields := strings.Fields(tag.Value[len(event)+2:])
+		if field > len(fields) {
+			return "-"
+		}
+		return fields[field-1]
+	}
+	return "-"
+}
+
+func (r *record) timestampFloat(event string) (float64, bool) {
+	f, err := strconv.ParseFloat(r.timestamp(event, 1), 64)
+	return f, err == nil
+}
+
+// start returns the time the request processing started.
+func (r *record) start() (time.Time, bool) {
+	f, ok := r.timestampFloat(vslparser.TimestampReqEventStart)
+	if !ok {
+		return time.Time{}, false
+	}
+	sec := int64(f)
+	usec := int64((f-float64(sec))*1e6 + 0.5)
+	return time.Unix(sec, usec*1e3), true
+}
+
+// duration returns the time taken to serve the request in units of 1/scale
+// seconds, truncated like varnishncsa does.
+func (r *record) duration(scale float64) string {
+	start, ok := r.timestampFloat(vslparser.TimestampReqEventStart)
+	if !ok {
+		return "-"
+	}
+
+	var (
+		end    float64
+		endSet bool
+	)
+	if r.backend {
+		for _, event := range []string{vslparser.TimestampBeReqEventBerespBody, vslparser.TimestampBeReqEventError, vslparser.TimestampBeReqEventRetry} {
+			if end, endSet = r.timestampFloat(event); endSet {
+				break
+			}
+		}
+	} else {
+		end, endSet = r.timestampFloat(vslparser.TimestampReqEventResp)
+	}
+	if !endSet {
+		return "-"
+	}
+	return strconv.FormatInt(int64((end-start)*scale), 10)
+}
+
+// handling returns how the request was handled, i.e. the last of the HIT,
+// MISS, PASS, PIPE and SYNTH VCL subroutines called, in lower case.
+func (r *record) handling() string {
+	handling := "-"
+	for _, tag := range r.entry.Tags {
+		if tag.Key != vslparser.TagVCLCall {
+			continue
+		}
+		switch tag.Value {
+		case "HIT", "MISS", "PASS", "PIPE", "SYNTH":
+			handling = strings.ToLower(tag.Value)
+		}
+	}
+	return handling
+}
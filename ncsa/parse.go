@@ -0,0 +1,450 @@
+package ncsa
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Showmax/vslparser"
+)
+
+// ErrMismatch is returned by Parser.Parse for lines which don't match the
+// format string.
+var ErrMismatch = errors.New("line doesn't match format")
+
+// Record is a varnishncsa log line parsed back into typed fields. Fields which
+// are not part of the format string or which were logged as '-' are left
+// zero.
+type Record struct {
+	// RemoteHost is %h.
+	RemoteHost string
+	// User is %u.
+	User string
+	// Time is %t or %{...}t. Only the time conversions which can be parsed
+	// back are supported, the zero Time is set otherwise.
+	Time time.Time
+	// Method, URL and Protocol are %m, %U%q and %H or the parts of %r.
+	// URL includes the query string, if any.
+	Method, URL, Protocol string
+	// Status is %s.
+	Status int
+	// BodyBytes, BytesReceived and BytesSent are %b, %I and %O.
+	BodyBytes, BytesReceived, BytesSent int64
+	// Duration is %D or %T.
+	Duration time.Duration
+	// FirstByte is %{Varnish:time_firstbyte}x.
+	FirstByte time.Duration
+	// Handling is %{Varnish:handling}x or %{Varnish:hitmiss}x, e.g. "hit"
+	// or "pass".
+	Handling string
+	// Backend tells whether the line describes a backend request, i.e.
+	// %{Varnish:side}x is "b".
+	Backend bool
+	// VXID is %{Varnish:vxid}x.
+	VXID vslparser.VXID
+	// ReqHeader and RespHeader hold %{...}i and %{...}o headers. They are
+	// nil if the format has no such directives.
+	ReqHeader, RespHeader http.Header
+	// Fields holds raw values of all directives keyed by the directive as
+	// written in the format string, e.g. "%s" or "%{Referer}i".
+	Fields map[string]string
+
+	// Entry is a synthetic entry holding tags implied by the format string,
+	// e.g. RespStatus for %s or ReqHeader for %{Referer}i, so that the
+	// record can be processed like entries parsed from varnishlog output.
+	// Its Kind is KindBeReq for backend records, KindRequest otherwise.
+	Entry vslparser.Entry
+
+	// host is the Host header of %r.
+	host string
+	// has records which directives were present and not '-'.
+	has map[byte]bool
+	// tags are raw tags of %{VSL:...}x directives.
+	tags []vslparser.Tag
+}
+
+// Parser parses lines of varnishncsa output back into Records.
+type Parser struct {
+	re     *regexp.Regexp
+	fields []token
+	json   bool
+}
+
+// NewParser creates a parser of lines produced by varnishncsa using format.
+func NewParser(format string) (*Parser, error) {
+	return newParser(format, false)
+}
+
+// NewJSONParser creates a parser of lines produced by varnishncsa -j, i.e.
+// with values escaped for use in JSON strings, using format, e.g.
+//
+//	{"status": %s, "url": "%U", "agent": "%{User-agent}i"}
+//
+// Besides '-', null is accepted for missing numeric values.
+func NewJSONParser(format string) (*Parser, error) {
+	return newParser(format, true)
+}
+
+func newParser(format string, jsonMode bool) (*Parser, error) {
+	tokens, err := tokenize(format)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Parser{json: jsonMode}
+	var expr strings.Builder
+	expr.WriteByte('^')
+	for _, t := range tokens {
+		if t.verb == 0 {
+			expr.WriteString(regexp.QuoteMeta(t.literal))
+			continue
+		}
+		pattern, err := p.pattern(t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid format %q: %w", format, err)
+		}
+		expr.WriteString("(" + pattern + ")")
+		p.fields = append(p.fields, t)
+	}
+	expr.WriteByte('$')
+
+	p.re, err = regexp.Compile(expr.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid format %q: %w", format, err)
+	}
+	return p, nil
+}
+
+// MustNewParser is like NewParser but panics if the format is invalid.
+func MustNewParser(format string) *Parser {
+	p, err := NewParser(format)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// pattern returns regular expression matching values of directive t.
+func (p *Parser) pattern(t token) (string, error) {
+	var numeric, text string
+	switch t.verb {
+	case 's':
+		numeric = `\d{3}`
+	case 'b', 'I', 'O', 'D', 'T':
+		numeric = `\d+`
+	case 'h', 'l', 'u', 'm', 'H':
+		text = `\S+`
+	case 'U', 'q':
+		text = `\S*`
+	case 't':
+		if t.arg == "" {
+			text = `\[[^\]]+\]`
+		} else {
+			text = `.*?`
+		}
+	case 'r', 'i', 'o':
+		if t.arg == "" && t.verb != 'r' {
+			return "", fmt.Errorf("%%%c requires a header name", t.verb)
+		}
+		text = `.*?`
+	case 'x':
+		if _, err := compileExtended(t.arg); err != nil {
+			return "", err
+		}
+		text = `.*?`
+	default:
+		return "", fmt.Errorf("unknown directive %%%c", t.verb)
+	}
+
+	if numeric != "" {
+		if p.json {
+			return numeric + `|-|null`, nil
+		}
+		return numeric + `|-`, nil
+	}
+	if p.json && text == `.*?` {
+		// Values are escaped, so they never contain an unescaped quote.
+		return `(?:[^"\\]|\\.)*?`, nil
+	}
+	return text, nil
+}
+
+// Parse parses a single line, without the line terminator.
+func (p *Parser) Parse(line string) (Record, error) {
+	m := p.re.FindStringSubmatch(line)
+	if m == nil {
+		return Record{}, fmt.Errorf("%w: %q", ErrMismatch, line)
+	}
+
+	r := Record{
+		Fields: make(map[string]string, len(p.fields)),
+		has:    make(map[byte]bool),
+	}
+	var path, query string
+	for i, t := range p.fields {
+		v := m[i+1]
+		if p.json {
+			var err error
+			if v, err = unescapeJSON(v); err != nil {
+				return Record{}, fmt.Errorf("invalid value %q of %s: %w", m[i+1], t, err)
+			}
+		}
+
+		if _, ok := r.Fields[t.String()]; !ok {
+			r.Fields[t.String()] = v
+		}
+		if v == "-" || (p.json && v == "null") {
+			continue
+		}
+
+		var err error
+		switch t.verb {
+		case 'h':
+			r.RemoteHost = v
+		case 'u':
+			r.User = v
+		case 't':
+			r.Time, err = parseTime(t.arg, v)
+		case 'm':
+			r.Method = v
+		case 'U':
+			path = v
+		case 'q':
+			query = v
+		case 'H':
+			r.Protocol = v
+		case 'r':
+			err = r.parseRequestLine(v)
+		case 's':
+			r.Status, err = strconv.Atoi(v)
+		case 'b':
+			r.BodyBytes, err = strconv.ParseInt(v, 10, 64)
+		case 'I':
+			r.BytesReceived, err = strconv.ParseInt(v, 10, 64)
+		case 'O':
+			r.BytesSent, err = strconv.ParseInt(v, 10, 64)
+		case 'D':
+			var us int64
+			us, err = strconv.ParseInt(v, 10, 64)
+			r.Duration = time.Duration(us) * time.Microsecond
+		case 'T':
+			if !r.has['D'] {
+				var s int64
+				s, err = strconv.ParseInt(v, 10, 64)
+				r.Duration = time.Duration(s) * time.Second
+			}
+		case 'i':
+			if r.ReqHeader == nil {
+				r.ReqHeader = make(http.Header)
+			}
+			r.ReqHeader.Add(t.arg, v)
+		case 'o':
+			if r.RespHeader == nil {
+				r.RespHeader = make(http.Header)
+			}
+			r.RespHeader.Add(t.arg, v)
+		case 'x':
+			err = r.parseExtended(t.arg, v)
+		}
+		if err != nil {
+			return Record{}, fmt.Errorf("invalid value %q of %s: %w", v, t, err)
+		}
+		r.has[t.verb] = true
+	}
+	if path != "" || query != "" {
+		r.URL = path + query
+	}
+
+	r.Entry = r.entry()
+	return r, nil
+}
+
+// String returns the directive as written in a format string.
+func (t token) String() string {
+	if t.verb == 0 {
+		return t.literal
+	}
+	if t.arg != "" {
+		return "%{" + t.arg + "}" + string(t.verb)
+	}
+	return "%" + string(t.verb)
+}
+
+// unescapeJSON decodes a value escaped for use in a JSON string.
+func unescapeJSON(v string) (string, error) {
+	if !strings.Contains(v, `\`) {
+		return v, nil
+	}
+	var s string
+	err := json.Unmarshal([]byte(`"`+v+`"`), &s)
+	return s, err
+}
+
+// parseTime parses %t, or %{layout}t if layout is not empty.
+func parseTime(layout, v string) (time.Time, error) {
+	if layout == "" {
+		return time.Parse("[02/Jan/2006:15:04:05 -0700]", v)
+	}
+	t, err := strptime(layout, v)
+	if errors.Is(err, errUnsupported) {
+		return time.Time{}, nil
+	}
+	return t, err
+}
+
+// parseRequestLine parses %r, e.g. "GET http://localhost/ HTTP/1.1".
+func (r *Record) parseRequestLine(v string) error {
+	fields := strings.Split(v, " ")
+	if len(fields) != 3 {
+		return errors.New("malformed request line")
+	}
+	r.Method, r.Protocol = fields[0], fields[2]
+
+	u := fields[1]
+	if strings.HasPrefix(u, "http://") {
+		u = u[len("http://"):]
+		if i := strings.IndexByte(u, '/'); i >= 0 {
+			r.host, u = u[:i], u[i:]
+		} else {
+			r.host, u = u, ""
+		}
+	}
+	r.URL = u
+	return nil
+}
+
+func (r *Record) parseExtended(arg, v string) error {
+	switch arg {
+	case "Varnish:handling":
+		r.Handling = v
+	case "Varnish:hitmiss":
+		if r.Handling == "" {
+			r.Handling = v
+		}
+	case "Varnish:side":
+		r.Backend = v == "b"
+	case "Varnish:time_firstbyte":
+		s, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		r.FirstByte = time.Duration(s * float64(time.Second))
+	case "Varnish:vxid":
+		vxid, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return err
+		}
+		r.VXID = vslparser.VXID(vxid)
+	default:
+		// Tags can be reconstructed only from whole values.
+		q, err := parseVSLQuery(arg[len("VSL:"):])
+		if err != nil {
+			return err
+		}
+		if q.field == 0 {
+			if q.prefix != "" {
+				v = q.prefix + ": " + v
+			}
+			r.tags = append(r.tags, vslparser.Tag{Key: q.tag, Value: v})
+		}
+	}
+	return nil
+}
+
+// entry creates a synthetic entry holding tags implied by the parsed
+// directives, in the order varnishlog would log them.
+func (r *Record) entry() vslparser.Entry {
+	e := vslparser.Entry{
+		Level: 1,
+		Kind:  vslparser.KindRequest,
+		VXID:  r.VXID,
+	}
+	key := func(client, backend string) string {
+		if r.Backend {
+			return backend
+		}
+		return client
+	}
+	add := func(key, value string) {
+		e.Tags = append(e.Tags, vslparser.Tag{Key: key, Value: value})
+	}
+	if r.Backend {
+		e.Kind = vslparser.KindBeReq
+		add(vslparser.TagBegin, "bereq 0 "+vslparser.ReasonFetch)
+	} else {
+		add(vslparser.TagBegin, "req 0 "+vslparser.ReasonRxreq)
+	}
+
+	var start float64
+	if !r.Time.IsZero() {
+		start = float64(r.Time.UnixNano()) / 1e9
+		add(vslparser.TagTimestamp, fmt.Sprintf("%s: %.6f 0.000000 0.000000", vslparser.TimestampReqEventStart, start))
+	}
+	if r.RemoteHost != "" && !r.Backend {
+		add(vslparser.TagReqStart, r.RemoteHost+" 0 -")
+	}
+	if r.Method != "" {
+		add(key(vslparser.TagReqMethod, vslparser.TagBeReqMethod), r.Method)
+	}
+	if r.URL != "" {
+		add(key(vslparser.TagReqURL, vslparser.TagBeReqURL), r.URL)
+	}
+	if r.Protocol != "" {
+		add(key(vslparser.TagReqProtocol, vslparser.TagBeReqProtocol), r.Protocol)
+	}
+	if r.host != "" && r.ReqHeader.Get("Host") == "" {
+		add(key(vslparser.TagReqHeader, vslparser.TagBeReqHeader), "Host: "+r.host)
+	}
+	addHeaders(&e, key(vslparser.TagReqHeader, vslparser.TagBeReqHeader), r.ReqHeader)
+	if r.Handling != "" && !r.Backend {
+		add(vslparser.TagVCLCall, strings.ToUpper(r.Handling))
+	}
+	if r.has['s'] {
+		add(key(vslparser.TagRespStatus, vslparser.TagBerespStatus), strconv.Itoa(r.Status))
+	}
+	addHeaders(&e, key(vslparser.TagRespHeader, vslparser.TagBeRespHeader), r.RespHeader)
+
+	if !r.Time.IsZero() {
+		firstByte := r.FirstByte.Seconds()
+		if r.FirstByte > 0 {
+			event := key(vslparser.TimestampReqEventProcess, vslparser.TimestampBeReqEventBeresp)
+			add(vslparser.TagTimestamp, fmt.Sprintf("%s: %.6f %.6f %.6f", event, start+firstByte, firstByte, firstByte))
+		}
+		if r.has['D'] || r.has['T'] {
+			d := r.Duration.Seconds()
+			event := key(vslparser.TimestampReqEventResp, vslparser.TimestampBeReqEventBerespBody)
+			add(vslparser.TagTimestamp, fmt.Sprintf("%s: %.6f %.6f %.6f", event, start+d, d, d-firstByte))
+		}
+	}
+	if r.has['b'] || r.has['I'] || r.has['O'] {
+		if r.Backend {
+			add(vslparser.TagBeReqAcct, fmt.Sprintf("0 0 %d 0 %d %d", r.BytesSent, r.BodyBytes, r.BytesReceived))
+		} else {
+			add(vslparser.TagReqAcct, fmt.Sprintf("0 0 %d 0 %d %d", r.BytesReceived, r.BodyBytes, r.BytesSent))
+		}
+	}
+	e.Tags = append(e.Tags, r.tags...)
+	add(vslparser.TagEnd, "")
+
+	return e
+}
+
+// addHeaders adds a tag with key for each header of h, sorted by name.
+func addHeaders(e *vslparser.Entry, key string, h http.Header) {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range h[name] {
+			e.Tags = append(e.Tags, vslparser.Tag{Key: key, Value: name + ": " + v})
+		}
+	}
+}
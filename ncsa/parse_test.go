@@ -0,0 +1,166 @@
+package ncsa_test
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	vsl "github.com/Showmax/vslparser"
+	"github.com/Showmax/vslparser/internal/testlog"
+	"github.com/Showmax/vslparser/ncsa"
+)
+
+func TestParser(t *testing.T) {
+	data, err := os.ReadFile(testlog.Path("varnishncsa_request.txt"))
+	if err != nil {
+		t.Fatalf("cannot read test data: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+
+	p := ncsa.MustNewParser(ncsa.DefaultFormat)
+	r, err := p.Parse(lines[2])
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	if r.RemoteHost != "127.0.0.1" || r.Method != "PUT" || r.URL != "/foo?param=val" || r.Protocol != "HTTP/1.1" {
+		t.Errorf("unexpected request %q %q %q %q", r.RemoteHost, r.Method, r.URL, r.Protocol)
+	}
+	if r.Status != 503 || r.BodyBytes != 282 {
+		t.Errorf("Status, BodyBytes = %d, %d, want 503, 282", r.Status, r.BodyBytes)
+	}
+	if want := time.Date(2022, time.March, 7, 22, 52, 24, 0, time.UTC); !r.Time.Equal(want) {
+		t.Errorf("Time = %v, want %v", r.Time, want)
+	}
+	if want := (http.Header{"User-Agent": {"curl/7.82.0"}}); !reflect.DeepEqual(r.ReqHeader, want) {
+		t.Errorf("ReqHeader = %v, want %v", r.ReqHeader, want)
+	}
+	if got := r.Fields["%{Referer}i"]; got != "-" {
+		t.Errorf(`Fields["%%{Referer}i"] = %q, want "-"`, got)
+	}
+
+	wantTags := []vsl.Tag{
+		{Key: "Begin", Value: "req 0 rxreq"},
+		{Key: "Timestamp", Value: "Start: 1646693544.000000 0.000000 0.000000"},
+		{Key: "ReqStart", Value: "127.0.0.1 0 -"},
+		{Key: "ReqMethod", Value: "PUT"},
+		{Key: "ReqURL", Value: "/foo?param=val"},
+		{Key: "ReqProtocol", Value: "HTTP/1.1"},
+		{Key: "ReqHeader", Value: "Host: localhost:6081"},
+		{Key: "ReqHeader", Value: "User-Agent: curl/7.82.0"},
+		{Key: "RespStatus", Value: "503"},
+		{Key: "ReqAcct", Value: "0 0 0 0 282 0"},
+		{Key: "End", Value: ""},
+	}
+	if r.Entry.Kind != vsl.KindRequest || !reflect.DeepEqual(r.Entry.Tags, wantTags) {
+		t.Errorf("Entry = %+v, want tags %+v", r.Entry, wantTags)
+	}
+}
+
+func TestParser_RoundTrip(t *testing.T) {
+	const format = `%h %{%Y-%m-%dT%H:%M:%S%z}t "%m %U%q %H" %s %b %I %O %D %{Varnish:handling}x %{Varnish:vxid}x %{VSL:VCL_use}x`
+
+	group := testlog.RequestGroups(t)[2]
+	f := ncsa.MustNewFormatter(format)
+	f.Location = time.UTC
+	line := f.Format(group[0])
+
+	r, err := ncsa.MustNewParser(format).Parse(line)
+	if err != nil {
+		t.Fatalf("Parse(%q) failed: %v", line, err)
+	}
+	if r.VXID != 32770 || r.Handling != "pass" || r.Duration != 1019*time.Microsecond {
+		t.Errorf("VXID, Handling, Duration = %d, %q, %v", r.VXID, r.Handling, r.Duration)
+	}
+	if r.BytesReceived != 125 || r.BytesSent != 532 || r.URL != "/foo?param=val" {
+		t.Errorf("BytesReceived, BytesSent, URL = %d, %d, %q", r.BytesReceived, r.BytesSent, r.URL)
+	}
+	if got := f.Format(r.Entry); got != line {
+		t.Errorf("Format(Entry) = %q, want %q", got, line)
+	}
+}
+
+func TestParser_Time(t *testing.T) {
+	march7 := time.Date(2022, time.March, 7, 22, 52, 24, 0, time.UTC)
+	tests := []struct {
+		layout, value string
+		want          time.Time
+	}{
+		{"%d/%b/%Y:%T %z", "07/Mar/2022:22:52:24 +0000", march7},
+		{"%F %T %Z", "2022-03-07 22:52:24 UTC", march7},
+		{"%c", "Mon Mar  7 22:52:24 2022", march7},
+		{"%A, %e %B %Y %H:%M:%S", "Monday,  7 March 2022 22:52:24", march7},
+		{"%Y%m%d%H%M%S", "20220307225224", march7},
+		{"%s", "1646693544", march7},
+		{"%D %R", "03/07/22 22:52", march7.Add(-24 * time.Second)},
+		{"%Y day %j", "2022 day 066", time.Date(2022, time.March, 7, 0, 0, 0, 0, time.UTC)},
+		{"%C%y-%m-%d %I:%M:%S %p", "2022-03-07 10:52:24 PM", march7},
+		{"%d.%m.%Y %I %p", "07.03.2022 12 AM", time.Date(2022, time.March, 7, 0, 0, 0, 0, time.UTC)},
+		// Missing day defaults to the first day of the month.
+		{"%b %Y", "Mar 2022", time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		{"%m/%Y", "03/2022", time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		// Literal text which looks like time.Parse layout elements.
+		{"Mon Jan 2006 PM %F", "Mon Jan 2006 PM 2022-03-07", time.Date(2022, time.March, 7, 0, 0, 0, 0, time.UTC)},
+		{"%Y-%m-%d 15:04:05 %T", "2022-03-07 15:04:05 22:52:24", march7},
+		// Week numbers are not supported, the time is left zero.
+		{"%Y %U", "2022 10", time.Time{}},
+	}
+	for _, tt := range tests {
+		r, err := ncsa.MustNewParser("%{" + tt.layout + "}t").Parse(tt.value)
+		if err != nil {
+			t.Errorf("Parse(%q) with layout %q failed: %v", tt.value, tt.layout, err)
+			continue
+		}
+		if !r.Time.Equal(tt.want) {
+			t.Errorf("Parse(%q) with layout %q: Time = %v, want %v", tt.value, tt.layout, r.Time, tt.want)
+		}
+	}
+
+	for _, tt := range []struct{ layout, value string }{
+		{"%F", "2022-02-30"},
+		{"%F %T", "2022-03-07 24:00:00"},
+		{"%Y %j", "2022 366"},
+		{"%I %p", "13 PM"},
+		{"Mon %F", "Tue 2022-03-07"},
+		{"%d %b", "07 Foo"},
+	} {
+		if _, err := ncsa.MustNewParser("%{" + tt.layout + "}t").Parse(tt.value); err == nil {
+			t.Errorf("Parse(%q) with layout %q should fail", tt.value, tt.layout)
+		}
+	}
+}
+
+func TestJSONParser(t *testing.T) {
+	p, err := ncsa.NewJSONParser(`{"status": %s, "bytes": %b, "url": "%U", "agent": "%{User-agent}i", "side": "%{Varnish:side}x"}`)
+	if err != nil {
+		t.Fatalf("NewJSONParser() failed: %v", err)
+	}
+
+	r, err := p.Parse(`{"status": 200, "bytes": null, "url": "/a\"b", "agent": "x\\y é", "side": "b"}`)
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	if r.Status != 200 || r.BodyBytes != 0 || r.URL != `/a"b` || r.ReqHeader.Get("User-Agent") != `x\y é` {
+		t.Errorf("unexpected record %+v", r)
+	}
+	if !r.Backend || r.Entry.Kind != vsl.KindBeReq {
+		t.Errorf("Backend, Kind = %v, %q, want true, %q", r.Backend, r.Entry.Kind, vsl.KindBeReq)
+	}
+	if tag, ok := vsl.Tags(r.Entry.Tags).FirstWithKey("BerespStatus"); !ok || tag.Value != "200" {
+		t.Errorf("BerespStatus = %q, %v, want 200", tag.Value, ok)
+	}
+}
+
+func TestParser_Mismatch(t *testing.T) {
+	p := ncsa.MustNewParser(ncsa.DefaultFormat)
+	if _, err := p.Parse("garbage"); !errors.Is(err, ncsa.ErrMismatch) {
+		t.Errorf("Parse(garbage) = %v, want ErrMismatch", err)
+	}
+	if _, err := ncsa.NewParser("%Z"); err == nil {
+		t.Errorf("NewParser(%%Z) should fail")
+	}
+}
@@ -0,0 +1,334 @@
+package ncsa
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// strftime formats t according to the strftime(3) layout in the C locale.
+// Unsupported conversions are copied to the output unchanged.
+func strftime(layout string, t time.Time) string {
+	var b strings.Builder
+	for i := 0; i < len(layout); i++ {
+		if layout[i] != '%' || i+1 == len(layout) {
+			b.WriteByte(layout[i])
+			continue
+		}
+		i++
+		switch layout[i] {
+		case 'a':
+			b.WriteString(t.Format("Mon"))
+		case 'A':
+			b.WriteString(t.Format("Monday"))
+		case 'b', 'h':
+			b.WriteString(t.Format("Jan"))
+		case 'B':
+			b.WriteString(t.Format("January"))
+		case 'c':
+			b.WriteString(t.Format("Mon Jan _2 15:04:05 2006"))
+		case 'C':
+			b.WriteString(pad(t.Year()/100, 2))
+		case 'd':
+			b.WriteString(pad(t.Day(), 2))
+		case 'D':
+			b.WriteString(t.Format("01/02/06"))
+		case 'e':
+			b.WriteString(t.Format("_2"))
+		case 'F':
+			b.WriteString(t.Format("2006-01-02"))
+		case 'H':
+			b.WriteString(pad(t.Hour(), 2))
+		case 'I':
+			b.WriteString(t.Format("03"))
+		case 'j':
+			b.WriteString(pad(t.YearDay(), 3))
+		case 'm':
+			b.WriteString(pad(int(t.Month()), 2))
+		case 'M':
+			b.WriteString(pad(t.Minute(), 2))
+		case 'n':
+			b.WriteByte('\n')
+		case 'p':
+			b.WriteString(t.Format("PM"))
+		case 'R':
+			b.WriteString(t.Format("15:04"))
+		case 's':
+			b.WriteString(strconv.FormatInt(t.Unix(), 10))
+		case 'S':
+			b.WriteString(pad(t.Second(), 2))
+		case 't':
+			b.WriteByte('\t')
+		case 'T':
+			b.WriteString(t.Format("15:04:05"))
+		case 'u':
+			wd := int(t.Weekday())
+			if wd == 0 {
+				wd = 7
+			}
+			b.WriteString(strconv.Itoa(wd))
+		case 'w':
+			b.WriteString(strconv.Itoa(int(t.Weekday())))
+		case 'y':
+			b.WriteString(pad(t.Year()%100, 2))
+		case 'Y':
+			b.WriteString(strconv.Itoa(t.Year()))
+		case 'z':
+			b.WriteString(t.Format("-0700"))
+		case 'Z':
+			b.WriteString(t.Format("MST"))
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(layout[i])
+		}
+	}
+	return b.String()
+}
+
+// pad formats n with leading zeros to at least width digits.
+func pad(n, width int) string {
+	s := strconv.Itoa(n)
+	for len(s) < width {
+		s = "0" + s
+	}
+	return s
+}
+
+// errUnsupported is returned by strptime for layouts with conversions which
+// cannot be parsed back, e.g. week numbers.
+var errUnsupported = errors.New("unsupported conversion")
+
+// strptime parses v formatted by strftime with the same layout. Conversions
+// are parsed one by one and literal text of the layout has to match v as is.
+// Like time.Parse, it returns UTC unless the layout has a time zone.
+func strptime(layout, v string) (time.Time, error) {
+	var d date
+	rest, err := d.parse(layout, v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if rest != "" {
+		return time.Time{}, fmt.Errorf("extra text %q", rest)
+	}
+	return d.time()
+}
+
+// date collects fields parsed by strptime.
+type date struct {
+	year, century, yy, yday          int
+	month, day, hour, minute, second int
+	hasYear, hasCentury, hasYY       bool
+	hasMonth, hasDay, hasYDay        bool
+	hour12, pm                       bool
+	unix                             int64
+	hasUnix                          bool
+	// offset and zone are the %z and %Z values, e.g. "+0100" and "CET".
+	offset, zone string
+}
+
+// parse parses v according to layout into d and returns the rest of v.
+func (d *date) parse(layout, v string) (string, error) {
+	for i := 0; i < len(layout); i++ {
+		if layout[i] != '%' || i+1 == len(layout) {
+			if v == "" || v[0] != layout[i] {
+				return "", fmt.Errorf("%q doesn't match layout %q", v, layout[i:])
+			}
+			v = v[1:]
+			continue
+		}
+		i++
+		var err error
+		switch layout[i] {
+		case 'a', 'A':
+			_, v, err = name(v, 7, func(i int) string { return time.Weekday(i).String() })
+		case 'b', 'h', 'B':
+			d.month, v, err = name(v, 12, func(i int) string { return time.Month(i + 1).String() })
+			d.month++
+			d.hasMonth = true
+		case 'c':
+			v, err = d.parse("%a %b %e %H:%M:%S %Y", v)
+		case 'C':
+			d.century, v, err = number(v, 2)
+			d.hasCentury = true
+		case 'd':
+			d.day, v, err = number(v, 2)
+			d.hasDay = true
+		case 'D':
+			v, err = d.parse("%m/%d/%y", v)
+		case 'e':
+			d.day, v, err = number(strings.TrimPrefix(v, " "), 2)
+			d.hasDay = true
+		case 'F':
+			v, err = d.parse("%Y-%m-%d", v)
+		case 'H':
+			d.hour, v, err = number(v, 2)
+		case 'I':
+			d.hour, v, err = number(v, 2)
+			d.hour12 = true
+		case 'j':
+			d.yday, v, err = number(v, 3)
+			d.hasYDay = true
+		case 'm':
+			d.month, v, err = number(v, 2)
+			d.hasMonth = true
+		case 'M':
+			d.minute, v, err = number(v, 2)
+		case 'n':
+			v, err = d.parse("\n", v)
+		case 'p':
+			switch {
+			case strings.HasPrefix(v, "AM"):
+			case strings.HasPrefix(v, "PM"):
+				d.pm = true
+			default:
+				return "", fmt.Errorf("%q doesn't start with AM or PM", v)
+			}
+			v = v[2:]
+		case 'R':
+			v, err = d.parse("%H:%M", v)
+		case 's':
+			n := 0
+			if strings.HasPrefix(v, "-") {
+				n++
+			}
+			for n < len(v) && '0' <= v[n] && v[n] <= '9' {
+				n++
+			}
+			d.unix, err = strconv.ParseInt(v[:n], 10, 64)
+			v = v[n:]
+			d.hasUnix = true
+		case 'S':
+			d.second, v, err = number(v, 2)
+		case 't':
+			v, err = d.parse("\t", v)
+		case 'T':
+			v, err = d.parse("%H:%M:%S", v)
+		case 'u', 'w':
+			_, v, err = number(v, 1)
+		case 'y':
+			d.yy, v, err = number(v, 2)
+			d.hasYY = true
+		case 'Y':
+			d.year, v, err = number(v, 4)
+			d.hasYear = true
+		case 'z':
+			if len(v) < 5 || (v[0] != '+' && v[0] != '-') {
+				return "", fmt.Errorf("%q doesn't start with a time zone offset", v)
+			}
+			d.offset, v = v[:5], v[5:]
+		case 'Z':
+			n := 0
+			for n < len(v) && ('A' <= v[n] && v[n] <= 'Z' || 'a' <= v[n] && v[n] <= 'z') {
+				n++
+			}
+			if n == 0 {
+				return "", fmt.Errorf("%q doesn't start with a time zone name", v)
+			}
+			d.zone, v = v[:n], v[n:]
+		case '%':
+			if !strings.HasPrefix(v, "%") {
+				return "", fmt.Errorf("%q doesn't match layout %q", v, layout[i-1:])
+			}
+			v = v[1:]
+		default:
+			return "", errUnsupported
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return v, nil
+}
+
+// time returns the time described by d.
+func (d *date) time() (time.Time, error) {
+	if d.hasUnix {
+		return time.Unix(d.unix, 0), nil
+	}
+
+	year := d.year
+	switch {
+	case d.hasYear:
+	case d.hasYY && d.hasCentury:
+		year = d.century*100 + d.yy
+	case d.hasYY && d.yy >= 69:
+		year = 1900 + d.yy
+	case d.hasYY:
+		year = 2000 + d.yy
+	case d.hasCentury:
+		year = d.century * 100
+	}
+
+	month, day := d.month, d.day
+	if !d.hasMonth {
+		month = 1
+	}
+	if !d.hasDay {
+		day = 1
+	}
+	if d.hasYDay && !d.hasMonth && !d.hasDay {
+		t := time.Date(year, time.January, d.yday, 0, 0, 0, 0, time.UTC)
+		if d.yday < 1 || t.Year() != year {
+			return time.Time{}, fmt.Errorf("day of year %d out of range", d.yday)
+		}
+		month, day = int(t.Month()), t.Day()
+	}
+
+	hour := d.hour
+	if d.hour12 {
+		if hour < 1 || hour > 12 {
+			return time.Time{}, fmt.Errorf("hour %d out of range", hour)
+		}
+		hour %= 12
+		if d.pm {
+			hour += 12
+		}
+	}
+
+	// Let time.Parse validate the fields and resolve the time zone.
+	layout := "2006-01-02 15:04:05"
+	v := fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", year, month, day, hour, d.minute, d.second)
+	if d.offset != "" {
+		layout += " -0700"
+		v += " " + d.offset
+	}
+	if d.zone != "" {
+		layout += " MST"
+		v += " " + d.zone
+	}
+	return time.Parse(layout, v)
+}
+
+// name parses one of n names returned by nameOf(i), either in full or
+// abbreviated to three letters, and returns its index.
+func name(v string, n int, nameOf func(i int) string) (int, string, error) {
+	for _, full := range []bool{true, false} {
+		for i := 0; i < n; i++ {
+			s := nameOf(i)
+			if !full {
+				s = s[:3]
+			}
+			if strings.HasPrefix(v, s) {
+				return i, v[len(s):], nil
+			}
+		}
+	}
+	return 0, "", fmt.Errorf("%q doesn't start with a name", v)
+}
+
+// number parses a decimal number of at most width digits.
+func number(v string, width int) (int, string, error) {
+	n := 0
+	for n < width && n < len(v) && '0' <= v[n] && v[n] <= '9' {
+		n++
+	}
+	if n == 0 {
+		return 0, "", fmt.Errorf("%q doesn't start with a number", v)
+	}
+	i, err := strconv.Atoi(v[:n])
+	return i, v[n:], err
+}
@@ -1,7 +1,7 @@
 package vslparser
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
@@ -10,16 +10,20 @@ import (
 
 // EntryParser implements varnishlog entry-by-entry parsing functionality.
 type EntryParser struct {
-	scanner *bufio.Scanner
+	scanner *lineScanner
+	entries int // number of entries parsed so far
 }
 
 // NewEntryParser creates a new EntryParser reading & parsing r.
-func NewEntryParser(r io.Reader) *EntryParser {
+func NewEntryParser(r io.Reader, opts ...Option) *EntryParser {
 	return &EntryParser{
-		scanner: bufio.NewScanner(r),
+		scanner: newLineScanner(r, opts),
 	}
 }
 
+// Dropped returns amounts of input dropped so far in lenient mode.
+func (p *EntryParser) Dropped() DropStats { return p.scanner.dropped }
+
 // white returns whether the byte b is considered a whitespace character for
 // the purpose of parsing of the log.
 func white(b byte) bool {
@@ -54,30 +58,55 @@ func splitLine(s string) (string, string) {
 // is kept mostly in its textual form. Only basic processing, such as splitting
 // lines into fields with a key and a value, are performed. The Entry struct
 // provides various convenience methods which perform the subsequent parsing.
+//
+// Malformed input is reported as *ParseError, unless the parser is Lenient.
+// Errors of the underlying reader are returned as they are.
 func (p *EntryParser) Parse() (Entry, error) {
-	if err := skipEmptyLines(p.scanner); err != nil {
-		return Entry{}, err
+	for {
+		if err := skipEmptyLines(p.scanner); err != nil {
+			return Entry{}, err
+		}
+
+		start := p.scanner.line
+		e, err := parseEntry(p.scanner, p.entries)
+		p.entries++
+		var perr *ParseError
+		if err == nil || !p.scanner.opts.lenient || !errors.As(err, &perr) {
+			return e, err
+		}
+
+		// The current line needs to be considered only if it's not the
+		// header line of the dropped entry itself.
+		p.scanner.skipEntry(perr.Line != start)
+		p.scanner.drop(perr, start, 1, 0)
 	}
-	return parseEntry(p.scanner)
 }
 
-func parseEntry(scanner *bufio.Scanner) (Entry, error) {
+func parseEntry(scanner *lineScanner, index int) (Entry, error) {
 	var e Entry
 
+	fail := func(err error) (Entry, error) {
+		return Entry{}, scanner.parseError(index, err)
+	}
+
 	// Parse Parselog entry header, e.g.:
 	// *   << BeReq    >> 32086823
 	// *   << Request  >> 32742536
 	// *   << Session  >> 29236595
 	header := strings.Fields(scanner.Text())
-	if len(header) != 5 || !isFullOfAsterisks(header[0]) {
-		return Entry{}, fmt.Errorf("header line was expected")
+	if len(header) != 5 {
+		return fail(ErrMissingHeader)
+	}
+	level, ok := headerLevel(header[0])
+	if !ok {
+		return fail(ErrMissingHeader)
 	}
-	e.Level = len(header[0]) // number of asterisks
+	e.Level = level
 	e.Kind = header[2]
 
 	vxid, err := strconv.ParseUint(header[4], 10, 32)
 	if err != nil {
-		return Entry{}, fmt.Errorf("failed to parse VXID: %w", err)
+		return fail(fmt.Errorf("%w: %v", ErrBadVXID, err))
 	}
 	e.VXID = VXID(vxid)
 
@@ -91,9 +120,12 @@ func parseEntry(scanner *bufio.Scanner) (Entry, error) {
 
 		tag, err := parseTag(e.Level, line)
 		if err != nil {
-			return Entry{}, fmt.Errorf("tag parsing error on line %q: %w", line, err)
+			return fail(err)
 		}
 
+		if scanner.truncated {
+			e.Truncated = append(e.Truncated, len(e.Tags))
+		}
 		e.Tags = append(e.Tags, tag)
 
 		if tag.Key == TagEnd {
@@ -106,30 +138,82 @@ func parseEntry(scanner *bufio.Scanner) (Entry, error) {
 		return Entry{}, err
 	}
 	if !foundEnd {
-		return Entry{}, fmt.Errorf("unexpected EOF in the middle of a log entry")
+		return fail(ErrUnexpectedEOF)
 	}
 
 	return e, nil
 }
 
 func parseTag(level int, line string) (Tag, error) {
-	if line == "" {
-		return Tag{}, fmt.Errorf("unexpected empty line")
+	if line == "" || isHeaderLine(line) {
+		return Tag{}, ErrMissingEnd
 	}
 
-	if !hasDashPrefix(line, level) {
-		return Tag{}, fmt.Errorf("line does not start with %d dashes", level)
+	prefix := level
+	if level > 3 {
+		// varnishlog writes levels above 3 as "-4-", "-5-", etc.
+		p := "-" + strconv.Itoa(level) + "-"
+		if !strings.HasPrefix(line, p) {
+			return Tag{}, fmt.Errorf("%w: line does not start with %q", ErrBadIndent, p)
+		}
+		prefix = len(p)
+	} else if !hasDashPrefix(line, level) {
+		return Tag{}, fmt.Errorf("%w: line does not start with %d dashes", ErrBadIndent, level)
 	}
 
-	k, v := splitLine(line[level:])
+	k, v := splitLine(line[prefix:])
 	if k == "" {
-		return Tag{}, fmt.Errorf("empty key")
+		return Tag{}, ErrEmptyKey
 	}
 
 	return Tag{Key: k, Value: v}, nil
 }
 
-func skipEmptyLines(scanner *bufio.Scanner) error {
+// parseGroup parses a group of entries delimited by an empty line, which is
+// the output format of varnishlog with request or session grouping.
+func parseGroup(scanner *lineScanner, scanFailed string) ([]Entry, error) {
+	for {
+		start := scanner.line + 1
+
+		var entries []Entry
+		var perr *ParseError
+		for i := 0; scanner.Scan(); i++ {
+			// Empty line '\n\n' is group delimiter.
+			if len(scanner.Bytes()) == 0 {
+				return entries, nil
+			}
+
+			entry, err := parseEntry(scanner, i)
+			if err != nil {
+				if !scanner.opts.lenient || !errors.As(err, &perr) {
+					return nil, fmt.Errorf("cannot parse entry %d: %w", i, err)
+				}
+				break
+			}
+
+			entries = append(entries, entry)
+		}
+
+		if perr == nil {
+			if err := scanner.Err(); err != nil {
+				return nil, fmt.Errorf("%s: %w", scanFailed, err)
+			}
+
+			// We have reached EOF in the scanner internal reader. We
+			// might still have some entries already parsed, but as we
+			// haven't seen an empty line delimiter, we are almost
+			// certain that entries doesn't represent a complete group.
+			// So we drop it and return EOF as we will not see more
+			// full groups.
+			return nil, io.EOF
+		}
+
+		scanner.skipGroup()
+		scanner.drop(perr, start, len(entries)+1, 1)
+	}
+}
+
+func skipEmptyLines(scanner *lineScanner) error {
 	eof := true
 	for scanner.Scan() {
 		if len(scanner.Bytes()) > 0 {
@@ -170,3 +254,23 @@ func isFullOfAsterisks(s string) bool {
 	}
 	return true
 }
+
+// headerLevel returns the level of an entry given the first field of its
+// header line, which is either a number of asterisks, e.g. "**", or the level
+// enclosed in asterisks for levels above 3, e.g. "*4*".
+func headerLevel(s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+	if isFullOfAsterisks(s) {
+		return len(s), true
+	}
+	if len(s) < 3 || s[0] != '*' || s[len(s)-1] != '*' {
+		return 0, false
+	}
+	level, err := strconv.Atoi(s[1 : len(s)-1])
+	if err != nil || level <= 3 {
+		return 0, false
+	}
+	return level, true
+}
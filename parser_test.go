@@ -113,6 +113,67 @@ func TestEntryParser_Parse(t *testing.T) {
 	testEntryParseError(t, "* << Request >> 1")
 }
 
+// TestEntryParser_DeepLevels tests that entries nested deeper than level 3,
+// which varnishlog marks as "*4*" and "-4-", are parsed and that malformed
+// level markers are rejected.
+func TestEntryParser_DeepLevels(t *testing.T) {
+	testEntryParseOK(t, Entry{
+		Level: 4,
+		Kind:  "Request",
+		VXID:  7,
+		Tags: []Tag{
+			{"Begin", "req 6 esi"},
+			{"End", ""},
+		},
+	}, "*4* << Request  >> 7\n-4- Begin req 6 esi\n-4- End")
+	testEntryParseOK(t, Entry{
+		Level: 12,
+		Kind:  "BeReq",
+		VXID:  8,
+		Tags: []Tag{
+			{"End", ""},
+		},
+	}, "*12* << BeReq    >> 8\n-12- End")
+
+	samples := map[string]error{
+		"*x* << Request >> 7\n-x- End":         ErrMissingHeader,
+		"*3* << Request >> 7\n-3- End":         ErrMissingHeader,
+		"*-4* << Request >> 7\n--4- End":       ErrMissingHeader,
+		"**4** << Request >> 7\n-4- End":       ErrMissingHeader,
+		"*4 << Request >> 7\n-4- End":          ErrMissingHeader,
+		"*4* << Request >> 7\n-5- End":         ErrBadIndent,
+		"*4* << Request >> 7\n---- End":        ErrBadIndent,
+		"*4* << Request >> 7\n- End":           ErrBadIndent,
+		"*12* << Request >> 7\n-1- End":        ErrBadIndent,
+		"**** << Request >> 7\n---- End":       ErrBadIndent,
+		"*4* << Request >> 7\n-4-\n-4- End":    ErrEmptyKey,
+		"*4* << Request >> 7\n-4- Foo\n*4* <<": ErrMissingEnd,
+	}
+	for input, want := range samples {
+		_, err := NewEntryParser(strings.NewReader(input)).Parse()
+		require.ErrorIs(t, err, want, "parsing %q", input)
+	}
+}
+
+// TestRequestParser_DeepLevels tests that a request group with entries nested
+// deeper than level 3 is parsed.
+func TestRequestParser_DeepLevels(t *testing.T) {
+	input := "*   << Request  >> 1\n-   End\n" +
+		"**  << Request  >> 2\n--  End\n" +
+		"*** << Request  >> 3\n--- End\n" +
+		"*4* << Request  >> 4\n-4- End\n" +
+		"*5* << BeReq    >> 5\n-5- End\n\n"
+
+	entries, err := NewRequestParser(strings.NewReader(input)).Parse()
+	require.NoError(t, err)
+	require.Len(t, entries, 5)
+	for i, e := range entries {
+		require.Equal(t, i+1, e.Level)
+		require.Equal(t, VXID(i+1), e.VXID)
+		require.Equal(t, []Tag{{"End", ""}}, e.Tags)
+	}
+}
+
 func TestEOF(t *testing.T) {
 	f, err := os.Open(os.DevNull)
 	require.NoError(t, err)
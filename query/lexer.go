@@ -0,0 +1,181 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokNumber
+	tokOperator
+	tokLParen
+	tokRParen
+	tokLBrace
+	tokRBrace
+	tokLBracket
+	tokRBracket
+	tokColon
+	tokComma
+)
+
+// token is a lexical unit of a query. Pos is the byte offset of the token in
+// the query string.
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of query"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// SyntaxError is returned by Compile for malformed queries.
+type SyntaxError struct {
+	// Query is the whole query being compiled.
+	Query string
+	// Pos is the byte offset of the problem in Query.
+	Pos int
+	// Msg describes the problem.
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at position %d: %s", e.Pos, e.Msg)
+}
+
+// operators are sorted so that longer operators are matched first.
+var operators = []string{"==", "!=", "<=", ">=", "!~", "<", ">", "~"}
+
+// lex splits query into tokens.
+func lex(query string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '(' || c == ')' || c == '{' || c == '}' || c == '[' || c == ']' || c == ':' || c == ',':
+			tokens = append(tokens, token{kind: punctKind(c), text: query[i : i+1], pos: i})
+			i++
+			continue
+		case c == '"' || c == '\'':
+			s, n, err := lexString(query, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokString, text: s, pos: i})
+			i += n
+			continue
+		}
+
+		if op := matchOperator(query[i:]); op != "" {
+			tokens = append(tokens, token{kind: tokOperator, text: op, pos: i})
+			i += len(op)
+			continue
+		}
+
+		start := i
+		for i < len(query) && isWordByte(query[i]) {
+			i++
+		}
+		if start == i {
+			return nil, &SyntaxError{Query: query, Pos: i, Msg: fmt.Sprintf("unexpected character %q", c)}
+		}
+
+		word := query[start:i]
+		kind := tokWord
+		if isNumber(word) {
+			kind = tokNumber
+		}
+		tokens = append(tokens, token{kind: kind, text: word, pos: start})
+	}
+
+	return append(tokens, token{kind: tokEOF, pos: len(query)}), nil
+}
+
+// lexString lexes a quoted string starting at query[pos]. Backslash escapes
+// the quote character and the backslash itself, other escape sequences are
+// kept verbatim, so that regular expressions need no double escaping.
+func lexString(query string, pos int) (string, int, error) {
+	quote := query[pos]
+	var b strings.Builder
+	for i := pos + 1; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == quote:
+			return b.String(), i - pos + 1, nil
+		case c == '\\' && i+1 < len(query) && (query[i+1] == quote || query[i+1] == '\\'):
+			b.WriteByte(query[i+1])
+			i++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, &SyntaxError{Query: query, Pos: pos, Msg: "unterminated string"}
+}
+
+func matchOperator(s string) string {
+	for _, op := range operators {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+func punctKind(c byte) tokenKind {
+	switch c {
+	case '(':
+		return tokLParen
+	case ')':
+		return tokRParen
+	case '{':
+		return tokLBrace
+	case '}':
+		return tokRBrace
+	case '[':
+		return tokLBracket
+	case ']':
+		return tokRBracket
+	case ':':
+		return tokColon
+	}
+	return tokComma
+}
+
+// isWordByte reports whether c can be a part of a tag name, glob, keyword,
+// header name or a number.
+func isWordByte(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return c == '_' || c == '-' || c == '.' || c == '*' || c == '+'
+}
+
+func isNumber(s string) bool {
+	digits := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= '0' && c <= '9':
+			digits++
+		case c == '.':
+		case (c == '-' || c == '+') && i == 0:
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}
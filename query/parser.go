@@ -0,0 +1,250 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// parser is a recursive descent parser of the query language. Operator
+// precedence from the highest is: not, and, or.
+type parser struct {
+	query  string
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) errorf(tok token, format string, args ...interface{}) error {
+	return &SyntaxError{Query: p.query, Pos: tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// isKeyword reports whether tok is the keyword kw. Keywords are case
+// insensitive.
+func isKeyword(tok token, kw string) bool {
+	return tok.kind == tokWord && strings.EqualFold(tok.text, kw)
+}
+
+func (p *parser) parseOr() (node, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for isKeyword(p.peek(), "or") {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = orNode{l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for isKeyword(p.peek(), "and") {
+		p.next()
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l = andNode{l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if isKeyword(p.peek(), "not") {
+		p.next()
+		n, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n: n}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.peek()
+	if tok.kind == tokLParen {
+		p.next()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok := p.next(); tok.kind != tokRParen {
+			return nil, p.errorf(tok, "expected \")\", got %s", tok)
+		}
+		return n, nil
+	}
+	return p.parseTest()
+}
+
+func (p *parser) parseTest() (node, error) {
+	t := &recordTest{}
+
+	if p.peek().kind == tokLBrace {
+		if err := p.parseLevel(t); err != nil {
+			return nil, err
+		}
+	}
+
+	tok := p.next()
+	if tok.kind != tokWord {
+		return nil, p.errorf(tok, "expected tag name, got %s", tok)
+	}
+
+	if isKeyword(tok, "vxid") && t.levelCmp == levelAny {
+		t.vxid = true
+		op := p.peek()
+		if op.kind != tokOperator || op.text == "~" || op.text == "!~" {
+			return nil, p.errorf(op, "expected numeric operator after vxid, got %s", op)
+		}
+		return t, p.parseComparison(t)
+	}
+
+	for {
+		if err := checkGlob(tok.text); err != nil {
+			return nil, p.errorf(tok, "%s", err)
+		}
+		t.tags = append(t.tags, strings.ToLower(tok.text))
+		if p.peek().kind != tokComma {
+			break
+		}
+		p.next()
+		if tok = p.next(); tok.kind != tokWord {
+			return nil, p.errorf(tok, "expected tag name, got %s", tok)
+		}
+	}
+
+	if p.peek().kind == tokColon {
+		p.next()
+		tok := p.next()
+		if tok.kind != tokWord && tok.kind != tokNumber {
+			return nil, p.errorf(tok, "expected record prefix, got %s", tok)
+		}
+		t.prefix = tok.text
+	}
+
+	if p.peek().kind == tokLBracket {
+		p.next()
+		tok := p.next()
+		n, err := strconv.Atoi(tok.text)
+		if tok.kind != tokNumber || err != nil || n < 1 {
+			return nil, p.errorf(tok, "expected positive field index, got %s", tok)
+		}
+		t.field = n
+		if tok := p.next(); tok.kind != tokRBracket {
+			return nil, p.errorf(tok, "expected \"]\", got %s", tok)
+		}
+	}
+
+	return t, p.parseComparison(t)
+}
+
+// parseLevel parses level qualifier such as {2}, {2+} or {2-}.
+func (p *parser) parseLevel(t *recordTest) error {
+	p.next() // '{'
+
+	tok := p.next()
+	if tok.kind != tokWord && tok.kind != tokNumber {
+		return p.errorf(tok, "expected level, got %s", tok)
+	}
+
+	s := tok.text
+	t.levelCmp = levelEq
+	switch {
+	case strings.HasSuffix(s, "+"):
+		t.levelCmp = levelMin
+		s = s[:len(s)-1]
+	case strings.HasSuffix(s, "-"):
+		t.levelCmp = levelMax
+		s = s[:len(s)-1]
+	}
+	level, err := strconv.Atoi(s)
+	if err != nil || level < 0 {
+		return p.errorf(tok, "invalid level %s", tok)
+	}
+	t.level = level
+
+	if tok := p.next(); tok.kind != tokRBrace {
+		return p.errorf(tok, "expected \"}\", got %s", tok)
+	}
+	return nil
+}
+
+// parseComparison parses an optional operator with its operand.
+func (p *parser) parseComparison(t *recordTest) error {
+	op := p.peek()
+	switch {
+	case op.kind == tokOperator:
+	case isKeyword(op, "eq"), isKeyword(op, "ne"):
+	default:
+		// No operator, the test matches on record existence.
+		return nil
+	}
+	p.next()
+
+	operand := p.next()
+	switch op.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+		if operand.kind != tokNumber {
+			return p.errorf(operand, "expected number after %q, got %s", op.text, operand)
+		}
+		if strings.Contains(operand.text, ".") {
+			f, err := strconv.ParseFloat(operand.text, 64)
+			if err != nil {
+				return p.errorf(operand, "invalid float %s", operand)
+			}
+			t.cmp = floatComparison{op: op.text, operand: f}
+			return nil
+		}
+		n, err := strconv.ParseInt(operand.text, 10, 64)
+		if err != nil {
+			return p.errorf(operand, "invalid integer %s", operand)
+		}
+		t.cmp = intComparison{op: op.text, operand: n}
+	case "~", "!~":
+		if operand.kind != tokString {
+			return p.errorf(operand, "expected quoted regular expression after %q, got %s", op.text, operand)
+		}
+		re, err := regexp.Compile(operand.text)
+		if err != nil {
+			return p.errorf(operand, "invalid regular expression: %s", err)
+		}
+		t.cmp = regexpComparison{negate: op.text == "!~", re: re}
+	default: // eq, ne
+		if operand.kind != tokWord && operand.kind != tokString && operand.kind != tokNumber {
+			return p.errorf(operand, "expected string after %q, got %s", op.text, operand)
+		}
+		t.cmp = stringComparison{negate: strings.EqualFold(op.text, "ne"), operand: operand.text}
+	}
+	return nil
+}
+
+// checkGlob validates tag name or a glob, which may contain '*' only at its
+// start or end.
+func checkGlob(s string) error {
+	if strings.Contains(strings.Trim(s, "*"), "*") {
+		return fmt.Errorf("'*' allowed only at start or end of tag name %q", s)
+	}
+	if strings.Trim(s, "*") == "" && s != "*" {
+		return fmt.Errorf("invalid tag glob %q", s)
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+// Package query implements the VSL query language, as used by "varnishlog -q",
+// for filtering entries produced by vslparser.
+//
+// A query is made up of record tests combined with "and", "or", "not" and
+// parentheses. A record test selects records by tag and optionally compares
+// their values against an operand:
+//
+//	{level}taglist:prefix[field] operator operand
+//
+// For example:
+//
+//	RespStatus >= 500 and ReqURL ~ "^/api"
+//	ReqHeader:Host eq "example.com"
+//	Timestamp:Resp[3] > 1.0
+//	{2+}BerespStatus == 503
+//	vxid == 32770
+//
+// The level qualifier {N} selects transactions at level N of a group, {N+}
+// at level N and deeper and {N-} at level N and shallower. The taglist is
+// a comma separated list of tag names; a tag name may start or end with '*'
+// to form a glob. Tag names are matched case-insensitively. The prefix
+// selects only records which start with the prefix followed by a colon, which
+// is convenient for headers and timestamps. The field selects a whitespace
+// separated field of the value, counted from 1.
+//
+// Supported operators are numeric ==, !=, <, <=, >, >=, string eq and ne and
+// regular expression ~ and !~. The type of a numeric comparison is given by the
+// operand: integer operands compare the record as an integer, operands with
+// a decimal point compare it as a float. A record test without an operator
+// matches if any selected record exists.
+//
+// Regular expressions use the Go regexp syntax rather than PCRE, which
+// Varnish uses.
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Showmax/vslparser"
+)
+
+// Query is a compiled VSL query. It is safe for concurrent use.
+type Query struct {
+	expr string
+	root node
+}
+
+// Compile parses a query expression and returns, if successful, a Query that
+// can be used to match entries. Syntax errors are reported as *SyntaxError.
+func Compile(expr string) (*Query, error) {
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{query: expr, tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorf(tok, "unexpected %s", tok)
+	}
+
+	return &Query{expr: expr, root: root}, nil
+}
+
+// MustCompile is like Compile but panics if the expression cannot be parsed.
+func MustCompile(expr string) *Query {
+	q, err := Compile(expr)
+	if err != nil {
+		panic(fmt.Sprintf("query: Compile(%q): %s", expr, err))
+	}
+	return q
+}
+
+// String returns the source text used to compile the query.
+func (q *Query) String() string { return q.expr }
+
+// Match reports whether the single entry e matches the query.
+func (q *Query) Match(e vslparser.Entry) bool {
+	return q.root.eval([]vslparser.Entry{e})
+}
+
+// MatchGroup reports whether the group of entries (typically a result of
+// RequestParser.Parse or SessionParser.Parse) matches the query. Every record
+// test is evaluated against all records of all entries in the group, just
+// like varnishlog does.
+func (q *Query) MatchGroup(entries []vslparser.Entry) bool {
+	return q.root.eval(entries)
+}
+
+type node interface {
+	eval(entries []vslparser.Entry) bool
+}
+
+type andNode struct{ l, r node }
+
+func (n andNode) eval(entries []vslparser.Entry) bool {
+	return n.l.eval(entries) && n.r.eval(entries)
+}
+
+type orNode struct{ l, r node }
+
+func (n orNode) eval(entries []vslparser.Entry) bool {
+	return n.l.eval(entries) || n.r.eval(entries)
+}
+
+type notNode struct{ n node }
+
+func (n notNode) eval(entries []vslparser.Entry) bool {
+	return !n.n.eval(entries)
+}
+
+// levelCmp selects how recordTest.level is compared to Entry.Level.
+type levelCmp int
+
+const (
+	levelAny levelCmp = iota
+	levelEq
+	levelMin
+	levelMax
+)
+
+// recordTest is the leaf of a query.
+type recordTest struct {
+	levelCmp levelCmp
+	level    int
+
+	tags   []string // lower case tag names or globs
+	prefix string
+	field  int // indexed from 1, 0 means whole value
+
+	vxid bool // test compares VXID of the entry instead of records
+	cmp  comparison
+}
+
+func (t *recordTest) eval(entries []vslparser.Entry) bool {
+	for _, e := range entries {
+		if !t.matchLevel(e.Level) {
+			continue
+		}
+		if t.vxid {
+			if t.cmp.compare(strconv.FormatUint(uint64(e.VXID), 10)) {
+				return true
+			}
+			continue
+		}
+		for _, tag := range e.Tags {
+			if !t.matchTag(tag.Key) {
+				continue
+			}
+			v, ok := t.value(tag.Value)
+			if !ok {
+				continue
+			}
+			if t.cmp == nil || t.cmp.compare(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (t *recordTest) matchLevel(level int) bool {
+	switch t.levelCmp {
+	case levelEq:
+		return level == t.level
+	case levelMin:
+		return level >= t.level
+	case levelMax:
+		return level <= t.level
+	}
+	return true
+}
+
+func (t *recordTest) matchTag(key string) bool {
+	for _, pattern := range t.tags {
+		if matchGlob(pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// value extracts the part of the record value selected by the prefix and
+// field of the test.
+func (t *recordTest) value(v string) (string, bool) {
+	if t.prefix != "" {
+		if len(v) <= len(t.prefix) || v[len(t.prefix)] != ':' ||
+			!strings.EqualFold(v[:len(t.prefix)], t.prefix) {
+			return "", false
+		}
+		v = strings.TrimLeft(v[len(t.prefix)+1:], " \t")
+	}
+	if t.field > 0 {
+		fields := strings.Fields(v)
+		if len(fields) < t.field {
+			return "", false
+		}
+		v = fields[t.field-1]
+	}
+	return v, true
+}
+
+// matchGlob matches key against a lower case tag name optionally starting or
+// ending with '*'.
+func matchGlob(pattern, key string) bool {
+	key = strings.ToLower(key)
+	if pattern == "*" {
+		return true
+	}
+	prefix := strings.HasSuffix(pattern, "*")
+	suffix := strings.HasPrefix(pattern, "*")
+	pattern = strings.Trim(pattern, "*")
+	switch {
+	case prefix && suffix:
+		return strings.Contains(key, pattern)
+	case prefix:
+		return strings.HasPrefix(key, pattern)
+	case suffix:
+		return strings.HasSuffix(key, pattern)
+	}
+	return key == pattern
+}
+
+// comparison compares a record value against the operand of a test.
+type comparison interface {
+	compare(v string) bool
+}
+
+type intComparison struct {
+	op      string
+	operand int64
+}
+
+func (c intComparison) compare(v string) bool {
+	n, err := strconv.ParseInt(firstField(v), 10, 64)
+	if err != nil {
+		return false
+	}
+	switch {
+	case n < c.operand:
+		return compareOrdered(c.op, -1)
+	case n > c.operand:
+		return compareOrdered(c.op, 1)
+	}
+	return compareOrdered(c.op, 0)
+}
+
+type floatComparison struct {
+	op      string
+	operand float64
+}
+
+func (c floatComparison) compare(v string) bool {
+	f, err := strconv.ParseFloat(firstField(v), 64)
+	if err != nil {
+		return false
+	}
+	switch {
+	case f < c.operand:
+		return compareOrdered(c.op, -1)
+	case f > c.operand:
+		return compareOrdered(c.op, 1)
+	}
+	return compareOrdered(c.op, 0)
+}
+
+// compareOrdered evaluates op given the result of three-way comparison of the
+// record and the operand.
+func compareOrdered(op string, cmp int) bool {
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+type stringComparison struct {
+	negate  bool
+	operand string
+}
+
+func (c stringComparison) compare(v string) bool {
+	return (v == c.operand) != c.negate
+}
+
+type regexpComparison struct {
+	negate bool
+	re     *regexp.Regexp
+}
+
+func (c regexpComparison) compare(v string) bool {
+	return c.re.MatchString(v) != c.negate
+}
+
+func firstField(s string) string {
+	s = strings.TrimLeft(s, " \t")
+	if i := strings.IndexAny(s, " \t"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
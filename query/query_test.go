@@ -0,0 +1,98 @@
+package query_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Showmax/vslparser/internal/testlog"
+	"github.com/Showmax/vslparser/query"
+)
+
+func TestQuery_MatchGroup(t *testing.T) {
+	groups := testlog.RequestGroups(t)
+
+	tests := []struct {
+		query string
+		want  []bool // one result per group of the fixture
+	}{
+		{`RespStatus >= 500`, []bool{true, true, true}},
+		{`RespStatus == 200`, []bool{false, false, false}},
+		{`ReqMethod eq "POST"`, []bool{false, true, false}},
+		{`ReqMethod ne GET`, []bool{false, true, true}},
+		{`ReqURL ~ "^/foo"`, []bool{false, false, true}},
+		{`ReqURL !~ "^/foo"`, []bool{true, true, false}},
+		{`reqheader:MAGIC eq "aloha"`, []bool{false, false, true}},
+		{`ReqHeader:Host eq "localhost:6081" and ReqMethod eq "GET"`, []bool{true, false, false}},
+		{`ReqMethod eq "GET" or ReqMethod eq "PUT"`, []bool{true, false, true}},
+		{`not (ReqMethod eq "GET" or ReqMethod eq "PUT")`, []bool{false, true, false}},
+		{`Timestamp:Resp[2] > 0.0009`, []bool{false, false, true}},
+		{`Timestamp:Resp[3] > 1.0`, []bool{false, false, false}},
+		{`{2+}BerespStatus == 503`, []bool{true, true, true}},
+		{`{1}BerespStatus`, []bool{false, false, false}},
+		{`{2-}Bereq*:magic`, []bool{false, false, true}},
+		{`ReqAcct[1] > 80`, []bool{false, true, true}},
+		{`RespStatus,BerespStatus != 503`, []bool{false, false, false}},
+		{`*Acct`, []bool{true, true, true}},
+		{`vxid == 5`, []bool{false, true, false}},
+		{`Link:bereq`, []bool{false, false, false}},
+	}
+
+	for _, tt := range tests {
+		q, err := query.Compile(tt.query)
+		if err != nil {
+			t.Errorf("Compile(%q) failed: %v", tt.query, err)
+			continue
+		}
+		for i, group := range groups {
+			if got := q.MatchGroup(group); got != tt.want[i] {
+				t.Errorf("%q.MatchGroup(group %d) = %v, want %v", tt.query, i, got, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestQuery_Match(t *testing.T) {
+	groups := testlog.RequestGroups(t)
+	req, bereq := groups[0][0], groups[0][1]
+
+	q := query.MustCompile(`BerespStatus == 503`)
+	if q.Match(req) {
+		t.Errorf("%q matches the client request", q)
+	}
+	if !q.Match(bereq) {
+		t.Errorf("%q doesn't match the backend request", q)
+	}
+}
+
+func TestCompile_SyntaxError(t *testing.T) {
+	tests := []struct {
+		query string
+		pos   int
+	}{
+		{``, 0},
+		{`RespStatus >=`, 13},
+		{`RespStatus >= "500"`, 14},
+		{`RespStatus == 500 and`, 21},
+		{`(RespStatus == 500`, 18},
+		{`ReqURL ~ "(["`, 9},
+		{`ReqURL ~ "^/api`, 9},
+		{`{x}RespStatus`, 1},
+		{`Resp*Status`, 0},
+		{`Timestamp:Resp[0] > 1.0`, 15},
+		{`RespStatus == 500 RespStatus`, 18},
+		{`RespStatus @ 500`, 11},
+		{`vxid ~ "1"`, 5},
+	}
+
+	for _, tt := range tests {
+		_, err := query.Compile(tt.query)
+		var serr *query.SyntaxError
+		if !errors.As(err, &serr) {
+			t.Errorf("Compile(%q) error = %v, want *SyntaxError", tt.query, err)
+			continue
+		}
+		if serr.Pos != tt.pos {
+			t.Errorf("Compile(%q) error position = %d, want %d (%v)", tt.query, serr.Pos, tt.pos, err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package vslparser
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// RawParser implements varnishlog raw log (produced by "varnishlog -g raw"
+// command) parsing functionality.
+//
+// In raw mode, every line holds a single record, e.g.:
+//
+//	    0 CLI            - Rd ping
+//	32770 Begin          c req 32769 rxreq
+//	32771 BerespStatus   b 503
+type RawParser struct {
+	scanner *lineScanner
+	grouper recordGrouper
+}
+
+// NewRawParser creates a new RawParser reading & parsing r.
+func NewRawParser(r io.Reader) *RawParser {
+	return &RawParser{
+		scanner: newLineScanner(r, nil),
+	}
+}
+
+// ParseRecord parses a single record. Empty lines are skipped.
+func (p *RawParser) ParseRecord() (Record, error) {
+	if err := skipEmptyLines(p.scanner); err != nil {
+		return Record{}, err
+	}
+
+	line := p.scanner.Text()
+	rec, err := parseRecord(line)
+	if err != nil {
+		return Record{}, fmt.Errorf("record parsing error on line %q: %w", line, err)
+	}
+	return rec, nil
+}
+
+// Parse regroups records by their VXID and returns an Entry as soon as its End
+// record is seen. The returned entries have Level set to 1, as raw mode has
+// no notion of transaction nesting.
+//
+// Non-transactional records (VXID 0) are skipped. Transactions which are not
+// complete at the end of the stream are dropped and io.EOF is returned.
+//
+// Parse and ParseRecord should not be mixed on a single RawParser, as records
+// returned by ParseRecord are not added to any entry.
+func (p *RawParser) Parse() (Entry, error) {
+	for {
+		rec, err := p.ParseRecord()
+		if err != nil {
+			return Entry{}, err
+		}
+		if e, ok := p.grouper.add(rec); ok {
+			return e, nil
+		}
+	}
+}
+
+func parseRecord(line string) (Record, error) {
+	vxidStr, rest := splitLine(line)
+	key, rest := splitLine(rest)
+	marker, value := splitLine(rest)
+
+	vxid, err := strconv.ParseUint(vxidStr, 10, 32)
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to parse VXID: %w", err)
+	}
+	if key == "" {
+		return Record{}, fmt.Errorf("empty key")
+	}
+	if len(marker) != 1 || (marker[0] != MarkerClient && marker[0] != MarkerBackend && marker[0] != MarkerNone) {
+		return Record{}, fmt.Errorf("invalid record type %q", marker)
+	}
+
+	return Record{
+		VXID:   VXID(vxid),
+		Marker: marker[0],
+		Tag:    Tag{Key: key, Value: value},
+	}, nil
+}
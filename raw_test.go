@@ -0,0 +1,99 @@
+package vslparser
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const rawExample = `         0 CLI            - Rd ping
+         4 Begin          c sess 0 HTTP/1
+         5 Begin          c req 4 rxreq
+         5 ReqURL         c /post
+         6 Begin          b bereq 5 pass
+         6 BerespStatus   b 503
+         6 End            b
+         5 RespStatus     c 503
+         5 End            c
+
+         0 Backend_health - boot.default Still healthy 4---X-RH 5 3 5 0.000523 0.000487 HTTP/1.1 200 OK
+         4 SessClose      c REM_CLOSE 0.001
+         4 End            c
+         7 Begin          c req 4 rxreq
+`
+
+func TestRawParser_ParseRecord(t *testing.T) {
+	r := require.New(t)
+	parser := NewRawParser(strings.NewReader(rawExample))
+
+	rec, err := parser.ParseRecord()
+	r.NoError(err)
+	r.Equal(Record{VXID: 0, Marker: MarkerNone, Tag: Tag{"CLI", "Rd ping"}}, rec)
+
+	rec, err = parser.ParseRecord()
+	r.NoError(err)
+	r.Equal(Record{VXID: 4, Marker: MarkerClient, Tag: Tag{"Begin", "sess 0 HTTP/1"}}, rec)
+
+	for i := 0; i < 4; i++ {
+		_, err = parser.ParseRecord()
+		r.NoError(err)
+	}
+	rec, err = parser.ParseRecord()
+	r.NoError(err)
+	r.Equal(Record{VXID: 6, Marker: MarkerBackend, Tag: Tag{"End", ""}}, rec)
+
+	_, err = NewRawParser(strings.NewReader("foo Begin c req 1 rxreq")).ParseRecord()
+	r.Error(err)
+	_, err = NewRawParser(strings.NewReader("1 Begin x req 1 rxreq")).ParseRecord()
+	r.Error(err)
+}
+
+func TestRawParser_Parse(t *testing.T) {
+	r := require.New(t)
+	parser := NewRawParser(strings.NewReader(rawExample))
+
+	expected := []Entry{
+		{
+			Level: 1,
+			Kind:  KindBeReq,
+			VXID:  6,
+			Tags: []Tag{
+				{"Begin", "bereq 5 pass"},
+				{"BerespStatus", "503"},
+				{"End", ""},
+			},
+		},
+		{
+			Level: 1,
+			Kind:  KindRequest,
+			VXID:  5,
+			Tags: []Tag{
+				{"Begin", "req 4 rxreq"},
+				{"ReqURL", "/post"},
+				{"RespStatus", "503"},
+				{"End", ""},
+			},
+		},
+		{
+			Level: 1,
+			Kind:  KindSession,
+			VXID:  4,
+			Tags: []Tag{
+				{"Begin", "sess 0 HTTP/1"},
+				{"SessClose", "REM_CLOSE 0.001"},
+				{"End", ""},
+			},
+		},
+	}
+	for _, e := range expected {
+		got, err := parser.Parse()
+		r.NoError(err)
+		r.Equal(e, got)
+	}
+
+	// Request 7 is incomplete.
+	_, err := parser.Parse()
+	r.Equal(io.EOF, err)
+}
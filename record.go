@@ -0,0 +1,92 @@
+package vslparser
+
+// Record markers as printed by "varnishlog -g raw" in the type column.
+const (
+	// MarkerClient identifies records of client side transactions
+	// (sessions and requests).
+	MarkerClient = 'c'
+	// MarkerBackend identifies records of backend side transactions.
+	MarkerBackend = 'b'
+	// MarkerNone identifies records which don't belong to any transaction,
+	// e.g. CLI or Backend_health records.
+	MarkerNone = '-'
+)
+
+// Record is a single VSL record, the smallest unit Varnish writes into its
+// shared memory log. Records of a transaction are grouped into an Entry.
+type Record struct {
+	// VXID of the transaction the record belongs to. Zero for
+	// non-transactional records.
+	VXID VXID
+	// Marker is one of MarkerClient, MarkerBackend or MarkerNone.
+	Marker byte
+	Tag    Tag
+}
+
+// recordGrouper collects records into entries by their VXID. An entry is
+// complete once its End record is seen.
+type recordGrouper struct {
+	open map[VXID]*Entry
+}
+
+// add appends rec to the entry it belongs to. The entry is returned once rec
+// completes it. Non-transactional records (VXID 0) are ignored.
+func (g *recordGrouper) add(rec Record) (Entry, bool) {
+	if rec.VXID == 0 {
+		return Entry{}, false
+	}
+	if g.open == nil {
+		g.open = make(map[VXID]*Entry)
+	}
+
+	e, ok := g.open[rec.VXID]
+	if !ok {
+		e = &Entry{
+			Level: 1,
+			Kind:  markerKind(rec.Marker),
+			VXID:  rec.VXID,
+		}
+		g.open[rec.VXID] = e
+	}
+
+	if rec.Tag.Key == TagBegin {
+		if typ, _, _, ok := parseLinkValue(rec.Tag.Value); ok {
+			if kind := typeKind(typ); kind != "" {
+				e.Kind = kind
+			}
+		}
+	}
+	e.Tags = append(e.Tags, rec.Tag)
+
+	if rec.Tag.Key != TagEnd {
+		return Entry{}, false
+	}
+	delete(g.open, rec.VXID)
+	return *e, true
+}
+
+// typeKind translates transaction type used in Begin and Link tags to Entry
+// Kind.
+func typeKind(typ string) string {
+	switch typ {
+	case "sess":
+		return KindSession
+	case "req":
+		return KindRequest
+	case "bereq":
+		return KindBeReq
+	}
+	return ""
+}
+
+// markerKind guesses Entry Kind from a record marker. It's used only if the
+// transaction lacks a Begin record.
+func markerKind(marker byte) string {
+	switch marker {
+	case MarkerClient:
+		return KindRequest
+	case MarkerBackend:
+		return KindBeReq
+	}
+	return ""
+}
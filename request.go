@@ -1,48 +1,25 @@
 package vslparser
 
-import (
-	"bufio"
-	"fmt"
-	"io"
-)
+import "io"
 
 // RequestParser implements varnishlog request grouped log (produced by
 // "varnishlog -g request" command) parsing functionality.
 type RequestParser struct {
-	scanner *bufio.Scanner
+	scanner *lineScanner
 }
 
 // NewRequestParser creates a new RequestParser reading & parsing r.
-func NewRequestParser(r io.Reader) *RequestParser {
+func NewRequestParser(r io.Reader, opts ...Option) *RequestParser {
 	return &RequestParser{
-		scanner: bufio.NewScanner(r),
+		scanner: newLineScanner(r, opts),
 	}
 }
 
-func (p *RequestParser) Parse() ([]Entry, error) {
-	var entries []Entry
-	for i := 0; p.scanner.Scan(); i++ {
-		// Empty line '\n\n' is request log group delimiter.
-		if len(p.scanner.Bytes()) == 0 {
-			return entries, nil
-		}
-
-		entry, err := parseEntry(p.scanner)
-		if err != nil {
-			return nil, fmt.Errorf("cannot parse entry %d: %w", i, err)
-		}
-
-		entries = append(entries, entry)
-	}
+// Dropped returns amounts of input dropped so far in lenient mode.
+func (p *RequestParser) Dropped() DropStats { return p.scanner.dropped }
 
-	if err := p.scanner.Err(); err != nil {
-		return nil, fmt.Errorf("request scanning failed: %w", err)
-	}
-
-	// We have reached EOF in the scanner internal reader. We might still
-	// have some entries already parsed, but as we haven't seen an empty
-	// line delimiter, we are almost certain that entries doesn't represent
-	// a complete request log. So we drop it and return EOF as we will not
-	// see more full request logs.
-	return nil, io.EOF
+// Parse parses a single request group. If the input ends before the group
+// delimiter, the incomplete group is dropped and io.EOF is returned.
+func (p *RequestParser) Parse() ([]Entry, error) {
+	return parseGroup(p.scanner, "request scanning failed")
 }
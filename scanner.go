@@ -0,0 +1,314 @@
+package vslparser
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// Option configures a parser created by NewEntryParser, NewRequestParser or
+// NewSessionParser.
+type Option func(*options)
+
+type options struct {
+	lenient bool
+	onError func(err *ParseError)
+
+	maxLine  int
+	truncate bool
+}
+
+// MaxLineSize sets the maximum size of an input line in bytes. Lines longer
+// than that make the parser fail with bufio.ErrTooLong, unless
+// TruncateLongLines is used as well. Size <= 0 means no limit. The default
+// limit is bufio.MaxScanTokenSize.
+func MaxLineSize(size int) Option {
+	return func(o *options) {
+		o.maxLine = size
+	}
+}
+
+// TruncateLongLines makes the parser truncate lines longer than the maximum
+// line size (see MaxLineSize) instead of failing, so that a single huge
+// header or URL doesn't abort parsing of the whole stream. Indices of tags
+// with truncated values are listed in Entry.Truncated.
+func TruncateLongLines() Option {
+	return func(o *options) {
+		o.truncate = true
+	}
+}
+
+// Lenient makes the parser recover from malformed input instead of failing.
+//
+// When an entry cannot be parsed, EntryParser drops it and skips input up to
+// the next entry header line or an empty line. RequestParser and
+// SessionParser drop the whole group the entry belongs to and skip input up
+// to the next group delimiter (an empty line). Parsing then continues with
+// the following entry or group.
+//
+// The handler, if not nil, is called with a description of every dropped
+// piece of input. Amounts of dropped input are available from the Dropped
+// method of the parser. Errors of the underlying reader are not recoverable
+// and are always returned.
+func Lenient(handler func(err *ParseError)) Option {
+	return func(o *options) {
+		o.lenient = true
+		o.onError = handler
+	}
+}
+
+// Errors wrapped by ParseError classifying malformed input. Use errors.Is to
+// check for them.
+var (
+	// ErrMissingHeader means that an entry header line, e.g.
+	// "*   << Request  >> 32742536", was expected.
+	ErrMissingHeader = errors.New("header line was expected")
+	// ErrBadVXID means that VXID in an entry header line is invalid.
+	ErrBadVXID = errors.New("failed to parse VXID")
+	// ErrBadIndent means that a tag line doesn't start with the number of
+	// dashes matching the level of the entry.
+	ErrBadIndent = errors.New("bad indentation")
+	// ErrEmptyKey means that a tag line has no key.
+	ErrEmptyKey = errors.New("empty key")
+	// ErrMissingEnd means that an entry was interrupted by an empty line
+	// or a header line of another entry before its End tag.
+	ErrMissingEnd = errors.New("entry ended without End tag")
+	// ErrUnexpectedEOF means that input ended in the middle of an entry,
+	// i.e. the input is truncated.
+	ErrUnexpectedEOF = errors.New("unexpected EOF in the middle of a log entry")
+)
+
+// ParseError describes malformed input. Its Err wraps one of the
+// ErrMissingHeader, ErrBadVXID, ErrBadIndent, ErrEmptyKey, ErrMissingEnd and
+// ErrUnexpectedEOF errors.
+//
+// Failures of the underlying reader are never reported as ParseError, so that
+// corrupted input can be told apart from I/O errors.
+type ParseError struct {
+	// Line is the number of the offending line, counted from 1. In case of
+	// ErrUnexpectedEOF, it is the last line of the input.
+	Line int
+	// Offset is the byte offset of the start of the offending line.
+	Offset int64
+	// Entry is the index of the entry being parsed, counted from 0. It is
+	// the index within the group for RequestParser and SessionParser and
+	// the index within the whole input for EntryParser.
+	Entry int
+	// Text is the offending line. It is empty in case of ErrUnexpectedEOF.
+	Text string
+	// Err is the reason of the failure.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parse error on line %d (offset %d, entry %d) %q: %v",
+		e.Line, e.Offset, e.Entry, e.Text, e.Err)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// DropStats holds amounts of input dropped by a parser in lenient mode.
+type DropStats struct {
+	// Lines is the number of input lines dropped.
+	Lines int
+	// Entries is the number of entries dropped, including the entries
+	// which were parsed fine but belonged to a dropped group.
+	Entries int
+	// Groups is the number of request or session groups dropped.
+	Groups int
+}
+
+// lineScanner wraps bufio.Scanner to keep track of line numbers, to allow to
+// put back a single line and to recover from malformed input.
+type lineScanner struct {
+	scanner *bufio.Scanner
+	// line is the number of the current line, counted from 1.
+	line int
+	// offset is the byte offset of the current line.
+	offset int64
+	// read is the number of bytes consumed by the split function so far
+	// and next is the offset of the last line it returned.
+	read, next int64
+	unread     bool
+	// truncated tells whether the current line was truncated. skipping
+	// and nextTruncated are the split function state of line truncation.
+	truncated, nextTruncated, skipping bool
+
+	opts    options
+	dropped DropStats
+}
+
+func newLineScanner(r io.Reader, opts []Option) *lineScanner {
+	s := &lineScanner{
+		scanner: bufio.NewScanner(r),
+		opts: options{
+			maxLine: bufio.MaxScanTokenSize,
+		},
+	}
+	for _, opt := range opts {
+		opt(&s.opts)
+	}
+
+	switch {
+	case s.opts.maxLine <= 0:
+		s.scanner.Buffer(nil, math.MaxInt)
+		s.opts.truncate = false
+	case s.opts.truncate:
+		// Leave space for line terminator, so that lines of exactly
+		// maxLine bytes are not truncated.
+		s.scanner.Buffer(nil, s.opts.maxLine+2)
+	default:
+		s.scanner.Buffer(nil, s.opts.maxLine)
+	}
+	s.scanner.Split(s.splitLines)
+
+	return s
+}
+
+// splitLines wraps bufio.ScanLines to keep track of byte offsets of lines and
+// to truncate long lines.
+func (s *lineScanner) splitLines(data []byte, atEOF bool) (int, []byte, error) {
+	if s.skipping {
+		// Drop the rest of a truncated line.
+		advance := len(data)
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			advance = i + 1
+			s.skipping = false
+		}
+		s.read += int64(advance)
+		return advance, nil, nil
+	}
+
+	advance, token, err := bufio.ScanLines(data, atEOF)
+	if s.opts.truncate {
+		switch {
+		case token == nil && !atEOF && len(data) > s.opts.maxLine &&
+			!(len(data) == s.opts.maxLine+1 && data[s.opts.maxLine] == '\r'):
+			// The line is longer than maxLine, i.e. more than maxLine
+			// bytes were read and they are not a line of exactly
+			// maxLine bytes followed by an incomplete "\r\n".
+			advance, token = len(data), data[:s.opts.maxLine]
+			s.skipping = true
+			s.nextTruncated = true
+		case len(token) > s.opts.maxLine:
+			token = token[:s.opts.maxLine]
+			s.nextTruncated = true
+		case token != nil:
+			s.nextTruncated = false
+		}
+	}
+	if token != nil {
+		s.next = s.read
+	}
+	s.read += int64(advance)
+	return advance, token, err
+}
+
+// Scan advances to the next line, which is the current line again if it was
+// put back by unscan.
+func (s *lineScanner) Scan() bool {
+	if s.unread {
+		s.unread = false
+		return true
+	}
+	if !s.scanner.Scan() {
+		return false
+	}
+	s.line++
+	s.offset = s.next
+	s.truncated = s.nextTruncated
+	return true
+}
+
+func (s *lineScanner) Text() string  { return s.scanner.Text() }
+func (s *lineScanner) Bytes() []byte { return s.scanner.Bytes() }
+func (s *lineScanner) Err() error    { return s.scanner.Err() }
+
+// unscan puts the current line back, so that it's returned by the next Scan.
+func (s *lineScanner) unscan() { s.unread = true }
+
+// parseError creates ParseError describing the current line.
+func (s *lineScanner) parseError(entry int, err error) *ParseError {
+	perr := &ParseError{
+		Line:   s.line,
+		Offset: s.offset,
+		Entry:  entry,
+		Err:    err,
+	}
+	if !errors.Is(err, ErrUnexpectedEOF) {
+		perr.Text = s.Text()
+	}
+	return perr
+}
+
+// consumed returns the number of the last line which has been consumed, i.e.
+// not put back by unscan.
+func (s *lineScanner) consumed() int {
+	if s.unread {
+		return s.line - 1
+	}
+	return s.line
+}
+
+// skipEntry skips lines up to the next entry header line, which is put back,
+// or an empty line. If checkCurrent is set, the current line is considered
+// as well.
+func (s *lineScanner) skipEntry(checkCurrent bool) {
+	if checkCurrent {
+		if len(s.Bytes()) == 0 {
+			return
+		}
+		if isHeaderLine(s.Text()) {
+			s.unscan()
+			return
+		}
+	}
+	for s.Scan() {
+		if len(s.Bytes()) == 0 {
+			return
+		}
+		if isHeaderLine(s.Text()) {
+			s.unscan()
+			return
+		}
+	}
+}
+
+// skipGroup skips lines up to and including the next empty line, unless the
+// current line is empty already.
+func (s *lineScanner) skipGroup() {
+	if len(s.Bytes()) == 0 {
+		return
+	}
+	for s.Scan() {
+		if len(s.Bytes()) == 0 {
+			return
+		}
+	}
+}
+
+// drop accounts input dropped since line start and reports err to the error
+// handler.
+func (s *lineScanner) drop(err *ParseError, start, entries, groups int) {
+	s.dropped.Lines += s.consumed() - start + 1
+	s.dropped.Entries += entries
+	s.dropped.Groups += groups
+	if s.opts.onError != nil {
+		s.opts.onError(err)
+	}
+}
+
+// isHeaderLine reports whether s looks like an entry header line, e.g.
+// "*   << Request  >> 32742536".
+func isHeaderLine(s string) bool {
+	fields := strings.Fields(s)
+	if len(fields) < 2 || fields[1] != "<<" {
+		return false
+	}
+	_, ok := headerLevel(fields[0])
+	return ok
+}
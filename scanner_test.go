@@ -0,0 +1,204 @@
+package vslparser
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntryParser_Lenient(t *testing.T) {
+	r := require.New(t)
+
+	input := strings.Join([]string{
+		"* << BeReq >> 1", // 1
+		"- Begin bereq 0 fetch",
+		"- End",
+		"garbage", // 4
+		"- more garbage",
+		"* << BeReq >> 2", // 6: missing End
+		"- Begin bereq 0 fetch",
+		"* << BeReq >> 3", // 8
+		"- Begin bereq 0 fetch",
+		" - Foo bar", // 10: bad indent
+		"- End",
+		"",
+		"* << BeReq >> x", // 13: bad VXID
+		"- End",
+		"* << BeReq >> 4", // 15
+		"- End",
+		"* << BeReq >> 5", // 17: truncated
+		"- Begin bereq 0 fetch",
+	}, "\n")
+
+	var errs []*ParseError
+	parser := NewEntryParser(strings.NewReader(input), Lenient(func(err *ParseError) {
+		errs = append(errs, err)
+	}))
+
+	var vxids []VXID
+	for {
+		e, err := parser.Parse()
+		if err == io.EOF {
+			break
+		}
+		r.NoError(err)
+		vxids = append(vxids, e.VXID)
+	}
+	r.Equal([]VXID{1, 4}, vxids)
+
+	r.Len(errs, 5)
+	r.Equal(4, errs[0].Line)
+	r.Equal("garbage", errs[0].Text)
+	r.Equal(8, errs[1].Line)
+	r.Equal("* << BeReq >> 3", errs[1].Text)
+	r.Equal(10, errs[2].Line)
+	r.Equal(13, errs[3].Line)
+	r.Equal(18, errs[4].Line)
+
+	r.Equal(DropStats{Lines: 13, Entries: 5}, parser.Dropped())
+}
+
+func TestSessionParser_Lenient(t *testing.T) {
+	r := require.New(t)
+
+	broken := strings.Replace(sessionExample, "--  ReqURL", " -  ReqURL", 1)
+	input := sessionExample + broken + sessionExample
+
+	var errs []*ParseError
+	parser := NewSessionParser(strings.NewReader(input), Lenient(func(err *ParseError) {
+		errs = append(errs, err)
+	}))
+
+	for i := 0; i < 2; i++ {
+		entries, err := parser.Parse()
+		r.NoError(err)
+		r.Len(entries, 2)
+	}
+	_, err := parser.Parse()
+	r.Equal(io.EOF, err)
+
+	r.Len(errs, 1)
+	r.Equal(16, errs[0].Line)
+	r.Equal(DropStats{Lines: 9, Entries: 2, Groups: 1}, parser.Dropped())
+
+	// Strict mode fails on the same input.
+	parser = NewSessionParser(strings.NewReader(input))
+	_, err = parser.Parse()
+	r.NoError(err)
+	_, err = parser.Parse()
+	var perr *ParseError
+	r.ErrorAs(err, &perr)
+	r.Equal(16, perr.Line)
+}
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		input  string
+		target error
+		line   int
+		offset int64
+		entry  int
+	}{
+		{"- End", ErrMissingHeader, 1, 0, 0},
+		{"* << BeReq >> 1\n- End\n\n* << BeReq >> x\n- End", ErrBadVXID, 4, 23, 1},
+		{"* << BeReq >> 1\n-- Begin\n - End", ErrBadIndent, 3, 25, 0},
+		{"** << BeReq >> 1\n--\n-- End", ErrEmptyKey, 2, 17, 0},
+		{"* << BeReq >> 1\n- Begin\n* << BeReq >> 2\n- End", ErrMissingEnd, 3, 24, 0},
+		{"* << BeReq >> 1\r\n- Begin\r\n", ErrUnexpectedEOF, 2, 17, 0},
+	}
+
+	for _, tt := range tests {
+		parser := NewEntryParser(strings.NewReader(tt.input))
+		var err error
+		for err == nil {
+			_, err = parser.Parse()
+		}
+
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("parsing %q: expected ParseError, got %v", tt.input, err)
+			continue
+		}
+		if !errors.Is(err, tt.target) {
+			t.Errorf("parsing %q: expected %v, got %v", tt.input, tt.target, err)
+		}
+		if perr.Line != tt.line || perr.Offset != tt.offset || perr.Entry != tt.entry {
+			t.Errorf("parsing %q: expected line %d, offset %d, entry %d, got %v",
+				tt.input, tt.line, tt.offset, tt.entry, err)
+		}
+	}
+
+	// Errors of the underlying reader are passed through.
+	readErr := errors.New("read failed")
+	_, err := NewRequestParser(iotest.ErrReader(readErr)).Parse()
+	require.ErrorIs(t, err, readErr)
+	var perr *ParseError
+	require.False(t, errors.As(err, &perr))
+}
+
+func TestLongLines(t *testing.T) {
+	r := require.New(t)
+
+	long := strings.Repeat("x", 100)
+	input := "* << Request >> 1\n" +
+		"- ReqURL /" + long + "\n" +
+		"- ReqHeader Cookie: " + long + "\r\n" +
+		"- ReqProtocol HTTP/1.1\n" +
+		"- End\n"
+
+	_, err := NewEntryParser(strings.NewReader(input), MaxLineSize(64)).Parse()
+	r.ErrorIs(err, bufio.ErrTooLong)
+
+	e, err := NewEntryParser(strings.NewReader(input), MaxLineSize(0)).Parse()
+	r.NoError(err)
+	r.Equal("/"+long, e.Tags[0].Value)
+	r.Nil(e.Truncated)
+
+	parser := NewEntryParser(iotest.OneByteReader(strings.NewReader(input)),
+		MaxLineSize(32), TruncateLongLines())
+	e, err = parser.Parse()
+	r.NoError(err)
+	r.Equal(Entry{
+		Level: 1,
+		Kind:  KindRequest,
+		VXID:  1,
+		Tags: []Tag{
+			{Key: "ReqURL", Value: "/" + long[:22]},
+			{Key: "ReqHeader", Value: "Cookie: " + long[:12]},
+			{Key: "ReqProtocol", Value: "HTTP/1.1"},
+			{Key: "End", Value: ""},
+		},
+		Truncated: []int{0, 1},
+	}, e)
+	_, err = parser.Parse()
+	r.Equal(io.EOF, err)
+
+	// Lines of exactly the maximum size are not truncated, regardless of
+	// how the reader splits the input.
+	exact := "* << Request >> 1\n" +
+		"- ReqURL /" + long[:22] + "\n" +
+		"- ReqHeader Cookie: " + long[:12] + "\r\n" +
+		"- End\n"
+	for _, rd := range []io.Reader{strings.NewReader(exact), iotest.OneByteReader(strings.NewReader(exact))} {
+		e, err = NewEntryParser(rd, MaxLineSize(32), TruncateLongLines()).Parse()
+		r.NoError(err)
+		r.Equal("/"+long[:22], e.Tags[0].Value)
+		r.Equal("Cookie: "+long[:12], e.Tags[1].Value)
+		r.Nil(e.Truncated)
+	}
+
+	// Offsets account for the dropped parts of truncated lines.
+	parser = NewEntryParser(strings.NewReader(input+"garbage\n"), MaxLineSize(32), TruncateLongLines())
+	_, err = parser.Parse()
+	r.NoError(err)
+	_, err = parser.Parse()
+	var perr *ParseError
+	r.True(errors.As(err, &perr))
+	r.Equal(6, perr.Line)
+	r.Equal(int64(len(input)), perr.Offset)
+}
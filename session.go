@@ -1,24 +1,23 @@
 package vslparser
 
-import (
-	"bufio"
-	"fmt"
-	"io"
-)
+import "io"
 
 // SessionParser implements varnishlog session grouped log (produced by
 // "varnishlog -g session" command) parsing functionality.
 type SessionParser struct {
-	scanner *bufio.Scanner
+	scanner *lineScanner
 }
 
 // NewSessionParser creates a new SessionParser reading & parsing r.
-func NewSessionParser(r io.Reader) *SessionParser {
+func NewSessionParser(r io.Reader, opts ...Option) *SessionParser {
 	return &SessionParser{
-		scanner: bufio.NewScanner(r),
+		scanner: newLineScanner(r, opts),
 	}
 }
 
+// Dropped returns amounts of input dropped so far in lenient mode.
+func (p *SessionParser) Dropped() DropStats { return p.scanner.dropped }
+
 // Parse parses log stream produced by varnishlog with enabled grouping.
 // Presence of End tag is required.
 //
@@ -32,29 +31,5 @@ func NewSessionParser(r io.Reader) *SessionParser {
 //      --  ReqURL         /healthz
 //      --  End
 func (p *SessionParser) Parse() ([]Entry, error) {
-	var entries []Entry
-	for i := 0; p.scanner.Scan(); i++ {
-		// Empty line '\n\n' is session log group delimiter.
-		if len(p.scanner.Bytes()) == 0 {
-			return entries, nil
-		}
-
-		e, err := parseEntry(p.scanner)
-		if err != nil {
-			return nil, fmt.Errorf("cannot parse entry %d: %w", i, err)
-		}
-
-		entries = append(entries, e)
-	}
-
-	if err := p.scanner.Err(); err != nil {
-		return nil, fmt.Errorf("group scanning failed: %w", err)
-	}
-
-	// We have reached EOF in the scanner internal reader. We might still
-	// have some entries already parsed, but as we haven't seen an empty
-	// line delimiter, we are almost certain that entries doesn't represent
-	// a complete session log. So we drop it and return EOF as we will not
-	// see more full session logs.
-	return nil, io.EOF
+	return parseGroup(p.scanner, "group scanning failed")
 }
@@ -0,0 +1,157 @@
+package vslparser
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrStreamClosed is returned by Stream.Next once the stream has been closed.
+var ErrStreamClosed = errors.New("stream closed")
+
+// Stream is a common interface of streaming parsers regardless of grouping.
+//
+// Parsing runs in a background goroutine, so that Next can return as soon as
+// its context is done even if the underlying reader blocks. Each call of Next
+// returns one complete group: a single entry for entry (vxid) grouping or all
+// entries of a request or session group. A group which was being parsed when
+// the context got done is not lost. It is returned by the following call of
+// Next, so in-flight groups can be drained after cancellation by calling Next
+// with a fresh context.
+//
+// Partial groups are never returned. If the input ends in the middle of
+// a group, the group is dropped and the error of the underlying parser is
+// returned: io.EOF if the input ends between entries of the group, a parse
+// error if it ends in the middle of an entry.
+type Stream interface {
+	// Next returns the next group of entries. It returns io.EOF at the
+	// end of input, ctx.Err() if ctx is done before a group is available
+	// and ErrStreamClosed once the stream has been closed. Next must
+	// not be called concurrently.
+	Next(ctx context.Context) ([]Entry, error)
+	// Close stops parsing of further groups and makes any pending and
+	// subsequent Next calls return ErrStreamClosed. It does not interrupt
+	// a read which is already blocked; close the underlying reader (e.g.
+	// varnishlog pipe) to do so.
+	Close() error
+}
+
+// NewEntryStream creates a Stream returning single entries parsed by p.
+func NewEntryStream(p *EntryParser) Stream {
+	return newStream(func() ([]Entry, error) {
+		e, err := p.Parse()
+		if err != nil {
+			return nil, err
+		}
+		return []Entry{e}, nil
+	})
+}
+
+// NewRequestStream creates a Stream returning request groups parsed by p.
+// Empty groups (e.g. caused by repeated empty lines) are skipped.
+func NewRequestStream(p *RequestParser) Stream {
+	return newStream(p.Parse)
+}
+
+// NewSessionStream creates a Stream returning session groups parsed by p.
+// Empty groups (e.g. caused by repeated empty lines) are skipped.
+func NewSessionStream(p *SessionParser) Stream {
+	return newStream(p.Parse)
+}
+
+// streamResult is a single result of a parse function.
+type streamResult struct {
+	entries []Entry
+	err     error
+}
+
+// stream implements Stream on top of a parse function.
+type stream struct {
+	parse func() ([]Entry, error)
+
+	start   sync.Once
+	results chan streamResult
+	done    chan struct{}
+	close   sync.Once
+
+	// err is the terminal error, set by Next only.
+	err error
+}
+
+func newStream(parse func() ([]Entry, error)) *stream {
+	return &stream{
+		parse:   parse,
+		results: make(chan streamResult),
+		done:    make(chan struct{}),
+	}
+}
+
+// run parses groups and hands them over to Next until the parse function
+// fails or the stream is closed.
+func (s *stream) run() {
+	for {
+		entries, err := s.parse()
+		if err == nil && len(entries) == 0 {
+			continue
+		}
+
+		select {
+		case s.results <- streamResult{entries: entries, err: err}:
+		case <-s.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *stream) Next(ctx context.Context) ([]Entry, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.start.Do(func() { go s.run() })
+
+	select {
+	case <-s.done:
+		return nil, ErrStreamClosed
+	default:
+	}
+
+	select {
+	case res := <-s.results:
+		if res.err != nil {
+			s.err = res.err
+		}
+		return res.entries, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-s.done:
+		return nil, ErrStreamClosed
+	}
+}
+
+func (s *stream) Close() error {
+	s.close.Do(func() { close(s.done) })
+	return nil
+}
+
+// ForEach calls fn for every group returned by s until the end of input. It
+// returns nil at the end of input, otherwise the first error returned either
+// by s.Next or fn. If fn fails, s is closed to stop the parsing goroutine.
+func ForEach(ctx context.Context, s Stream, fn func(entries []Entry) error) error {
+	for {
+		entries, err := s.Next(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(entries); err != nil {
+			s.Close()
+			return err
+		}
+	}
+}
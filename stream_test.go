@@ -0,0 +1,107 @@
+package vslparser
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream_Cancel(t *testing.T) {
+	r := require.New(t)
+
+	pr, pw := io.Pipe()
+	s := NewSessionStream(NewSessionParser(pr))
+	defer s.Close()
+
+	half := len(sessionExample) / 2
+	go func() {
+		_, _ = pw.Write([]byte(sessionExample[:half]))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := s.Next(ctx)
+	r.ErrorIs(err, context.DeadlineExceeded)
+
+	// The in-flight group is not lost by the cancellation.
+	go func() {
+		_, _ = pw.Write([]byte(sessionExample[half:]))
+		_, _ = pw.Write([]byte(sessionExample[:len(sessionExample)-1]))
+		pw.Close()
+	}()
+	entries, err := s.Next(context.Background())
+	r.NoError(err)
+	r.Len(entries, 2)
+	r.Equal(VXID(413073608), entries[0].VXID)
+
+	// Partial group at the end of input is dropped.
+	_, err = s.Next(context.Background())
+	r.Equal(io.EOF, err)
+	_, err = s.Next(context.Background())
+	r.Equal(io.EOF, err)
+}
+
+func TestStream_Close(t *testing.T) {
+	r := require.New(t)
+
+	pr, _ := io.Pipe()
+	s := NewEntryStream(NewEntryParser(pr))
+	r.NoError(s.Close())
+
+	_, err := s.Next(context.Background())
+	r.Equal(ErrStreamClosed, err)
+}
+
+func TestForEach(t *testing.T) {
+	r := require.New(t)
+
+	file, err := os.Open("testdata/varnishlog_request.txt")
+	r.NoError(err)
+	defer file.Close()
+
+	var vxids []VXID
+	err = ForEach(context.Background(), NewRequestStream(NewRequestParser(file)), func(entries []Entry) error {
+		vxids = append(vxids, entries[0].VXID)
+		return nil
+	})
+	r.NoError(err)
+	r.Equal([]VXID{2, 5, 32770}, vxids)
+
+	var n int
+	s := NewEntryStream(NewEntryParser(strings.NewReader(sessionExample)))
+	r.NoError(ForEach(context.Background(), s, func(entries []Entry) error {
+		r.Len(entries, 1)
+		n++
+		return nil
+	}))
+	r.Equal(2, n)
+}
+
+func TestForEach_Error(t *testing.T) {
+	r := require.New(t)
+
+	// The parse function never runs out of groups, so the parsing goroutine
+	// would block on handing over the next one unless the stream is closed.
+	s := newStream(func() ([]Entry, error) {
+		return []Entry{{VXID: 1}}, nil
+	})
+	fail := errors.New("fail")
+	err := ForEach(context.Background(), s, func(entries []Entry) error {
+		return fail
+	})
+	r.Equal(fail, err)
+
+	select {
+	case <-s.done:
+	default:
+		r.Fail("stream is not closed")
+	}
+	_, err = s.Next(context.Background())
+	r.Equal(ErrStreamClosed, err)
+}
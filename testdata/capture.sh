@@ -0,0 +1,49 @@
+#!/bin/bash
+set -eu
+
+# The purpose of this script is to capture real Varnish output for tests. It is
+# supposed to be run inside the container built from the Dockerfile in this
+# directory (mounted as /output), which is done by `./run_docker.sh varnish
+# capture`.
+#
+# It sends the same requests as recorded in varnishlog_request.txt, followed
+# by a request which is restarted by capture.vcl, to Varnish with no backend
+# running, and writes the following files into the capture directory:
+#
+#   varnishlog.bin             binary log written by `varnishlog -w`
+#   varnishlog_vxid.txt        the same log printed by `varnishlog -r`
+#   varnishncsa_client_N.txt   the same log printed by `varnishncsa -r` with
+#                              the N-th line of varnishncsa_formats.txt as -F
+#   varnishncsa_backend_N.txt  the same with `varnishncsa -b`
+
+readonly out=/output/capture
+# varnishncsa renders %t in local time, tests expect UTC.
+export TZ=UTC
+mkdir -p "$out"
+
+/usr/sbin/varnishd -a :6081 -f /output/capture.vcl -s malloc,256m
+sleep 2
+
+varnishlog -w "$out/varnishlog.bin" &
+readonly pids="$!"
+sleep 1
+
+curl -s -o /dev/null http://localhost:6081/
+curl -s -o /dev/null -X POST http://localhost:6081/post
+curl -s -o /dev/null -X PUT -H 'magic: aloha' -H 'greeting: traveler' \
+	'http://localhost:6081/foo?param=val'
+curl -s -o /dev/null -H 'X-Restart: 1' http://localhost:6081/restart
+
+# Give the loggers time to see the ends of the transactions.
+sleep 2
+kill -INT $pids
+wait
+
+varnishlog -r "$out/varnishlog.bin" > "$out/varnishlog_vxid.txt"
+
+n=0
+while IFS= read -r format; do
+	n=$((n + 1))
+	varnishncsa -r "$out/varnishlog.bin" -F "$format" > "$out/varnishncsa_client_$n.txt"
+	varnishncsa -r "$out/varnishlog.bin" -b -F "$format" > "$out/varnishncsa_backend_$n.txt"
+done < /output/varnishncsa_formats.txt
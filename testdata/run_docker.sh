@@ -11,7 +11,15 @@ set -eu
 
 cd -- "$(dirname -- "$0")"
 
+# With "capture" as the second argument, the container runs ./capture.sh
+# instead of the shell to regenerate test data in the capture directory.
+
 readonly image_name="${1:-varnish}"
+readonly mode="${2:-shell}"
 
 docker build -t "$image_name" .
-docker run --rm -it -v "$(pwd)":/output "$image_name" /bin/bash
+if [ "$mode" = capture ]; then
+	docker run --rm -v "$(pwd)":/output "$image_name" /output/capture.sh
+else
+	docker run --rm -it -v "$(pwd)":/output "$image_name" /bin/bash
+fi
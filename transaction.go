@@ -0,0 +1,227 @@
+package vslparser
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Transaction is a single node of a transaction tree. It wraps an Entry and
+// links it with its parent and child transactions, e.g. a client Request with
+// the BeReq it triggered, its ESI sub-requests and restarts.
+type Transaction struct {
+	Entry Entry
+
+	// Type is the transaction type as reported by the Begin tag, e.g.
+	// "sess", "req" or "bereq". It is empty if the entry has no Begin tag.
+	Type string
+	// ParentVXID is the VXID of the parent transaction as reported by the
+	// Begin tag. Zero means there is no parent (e.g. for sessions).
+	ParentVXID VXID
+	// Reason is the reason of the transaction start as reported by the
+	// Begin tag, e.g. ReasonRxreq or ReasonESI.
+	Reason string
+
+	Parent   *Transaction
+	Children []*Transaction
+}
+
+// Walk calls fn for t and all of its descendants in depth-first pre-order,
+// i.e. in the order varnishlog prints them. Walking stops as soon as fn
+// returns false.
+func (t *Transaction) Walk(fn func(t *Transaction) bool) bool {
+	if !fn(t) {
+		return false
+	}
+	for _, c := range t.Children {
+		if !c.Walk(fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// DanglingLink describes a Link tag which points to a child VXID that is not
+// present among the entries the tree was built from.
+type DanglingLink struct {
+	// From is the transaction which contains the Link tag.
+	From *Transaction
+	// ChildType is the type of the linked transaction, e.g. "req" or
+	// "bereq".
+	ChildType string
+	// ChildVXID is the VXID of the missing transaction.
+	ChildVXID VXID
+	// Reason is the reason of the link, e.g. ReasonFetch or ReasonESI.
+	Reason string
+}
+
+// TransactionTree holds transactions built from a group of entries (typically
+// a result of RequestParser.Parse or SessionParser.Parse) organized into
+// a forest using Begin and Link tags.
+type TransactionTree struct {
+	// Roots are the top-level transactions in the order of appearance.
+	// Orphans are included as roots, so that they are not lost.
+	Roots []*Transaction
+	// Orphans are the transactions which are nested (Level > 1), but
+	// whose parent is not present among the entries or whose Begin tags
+	// form a cycle with their ancestors.
+	Orphans []*Transaction
+	// DanglingLinks are the Link tags pointing to missing transactions.
+	DanglingLinks []DanglingLink
+
+	lookup map[VXID]*Transaction
+}
+
+// NewTransactionTree builds a TransactionTree from entries.
+//
+// A transaction becomes a child of the transaction identified by the parent
+// VXID in its Begin tag, provided such a transaction is present. Otherwise it
+// becomes a root. Top-level entries (Level <= 1) are expected to have their
+// parent missing, e.g. the Session of a request grouped log is never printed.
+// Nested entries without their parent are reported as orphans.
+//
+// If Begin tags of transactions form a cycle (e.g. A is the parent of B and
+// B the parent of A), the cycle is broken at the transaction which appears
+// first, which becomes a root, so that no transaction is lost.
+//
+// If there are several entries with the same VXID, only the first one is
+// used as a link target.
+func NewTransactionTree(entries []Entry) *TransactionTree {
+	tree := &TransactionTree{
+		lookup: make(map[VXID]*Transaction, len(entries)),
+	}
+
+	txs := make([]*Transaction, len(entries))
+	for i, e := range entries {
+		tx := &Transaction{Entry: e}
+		if tag, ok := Tags(e.Tags).FirstWithKey(TagBegin); ok {
+			tx.Type, tx.ParentVXID, tx.Reason, _ = parseLinkValue(tag.Value)
+		}
+		if _, ok := tree.lookup[e.VXID]; !ok {
+			tree.lookup[e.VXID] = tx
+		}
+		txs[i] = tx
+	}
+
+	for _, tx := range txs {
+		parent, ok := tree.lookup[tx.ParentVXID]
+		if !ok || tx.ParentVXID == 0 || parent == tx {
+			continue
+		}
+		tx.Parent = parent
+		parent.Children = append(parent.Children, tx)
+	}
+	breakCycles(txs)
+
+	for _, tx := range txs {
+		if tx.Parent != nil {
+			continue
+		}
+		tree.Roots = append(tree.Roots, tx)
+		if tx.Entry.Level > 1 {
+			tree.Orphans = append(tree.Orphans, tx)
+		}
+	}
+
+	for _, tx := range txs {
+		for _, tag := range tx.Entry.Tags {
+			if tag.Key != TagLink {
+				continue
+			}
+			typ, child, reason, ok := parseLinkValue(tag.Value)
+			if !ok {
+				continue
+			}
+			if _, found := tree.lookup[child]; !found {
+				tree.DanglingLinks = append(tree.DanglingLinks, DanglingLink{
+					From:      tx,
+					ChildType: typ,
+					ChildVXID: child,
+					Reason:    reason,
+				})
+			}
+		}
+	}
+
+	return tree
+}
+
+// Lookup returns the transaction with VXID vxid.
+func (t *TransactionTree) Lookup(vxid VXID) (*Transaction, bool) {
+	tx, ok := t.lookup[vxid]
+	return tx, ok
+}
+
+// Walk calls fn for all transactions in the tree in depth-first pre-order.
+// Walking stops as soon as fn returns false.
+func (t *TransactionTree) Walk(fn func(t *Transaction) bool) {
+	for _, root := range t.Roots {
+		if !root.Walk(fn) {
+			return
+		}
+	}
+}
+
+// breakCycles detaches transactions from their parents where parents form a
+// cycle, which makes the transactions unreachable from any root. Each cycle
+// is broken at its transaction which comes first in txs.
+func breakCycles(txs []*Transaction) {
+	index := make(map[*Transaction]int, len(txs))
+	for i, tx := range txs {
+		index[tx] = i
+	}
+
+	reached := make(map[*Transaction]bool, len(txs))
+	mark := func(t *Transaction) bool {
+		reached[t] = true
+		return true
+	}
+	for _, tx := range txs {
+		if tx.Parent == nil {
+			tx.Walk(mark)
+		}
+	}
+
+	for _, tx := range txs {
+		if reached[tx] {
+			continue
+		}
+		// tx is either in a cycle or a descendant of one. Find a
+		// transaction of the cycle and then its first one.
+		seen := make(map[*Transaction]bool)
+		c := tx
+		for !seen[c] {
+			seen[c] = true
+			c = c.Parent
+		}
+		first := c
+		for p := c.Parent; p != c; p = p.Parent {
+			if index[p] < index[first] {
+				first = p
+			}
+		}
+
+		siblings := first.Parent.Children
+		for i, sibling := range siblings {
+			if sibling == first {
+				first.Parent.Children = append(siblings[:i:i], siblings[i+1:]...)
+				break
+			}
+		}
+		first.Parent = nil
+		first.Walk(mark)
+	}
+}
+
+// parseLinkValue parses value of Begin and Link tags, which share the same
+// layout, e.g. "req 413073608 rxreq" or "bereq 3 fetch".
+func parseLinkValue(s string) (typ string, vxid VXID, reason string, ok bool) {
+	fields := strings.Fields(s)
+	if len(fields) < 3 {
+		return "", 0, "", false
+	}
+	n, err := strconv.ParseUint(fields[1], 10, 32)
+	if err != nil {
+		return "", 0, "", false
+	}
+	return fields[0], VXID(n), fields[2], true
+}
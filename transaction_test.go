@@ -0,0 +1,151 @@
+package vslparser
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTransactionTree_Session(t *testing.T) {
+	r := require.New(t)
+
+	entries, err := NewSessionParser(strings.NewReader(sessionExample)).Parse()
+	r.NoError(err)
+
+	tree := NewTransactionTree(entries)
+	r.Len(tree.Roots, 1)
+	r.Empty(tree.Orphans)
+	r.Empty(tree.DanglingLinks)
+
+	sess := tree.Roots[0]
+	r.Equal(VXID(413073608), sess.Entry.VXID)
+	r.Equal("sess", sess.Type)
+	r.Nil(sess.Parent)
+	r.Len(sess.Children, 1)
+
+	req := sess.Children[0]
+	r.Equal(VXID(413073609), req.Entry.VXID)
+	r.Equal(ReasonRxreq, req.Reason)
+	r.Equal(VXID(413073608), req.ParentVXID)
+	r.Same(sess, req.Parent)
+
+	got, ok := tree.Lookup(413073609)
+	r.True(ok)
+	r.Same(req, got)
+}
+
+func TestNewTransactionTree_Request(t *testing.T) {
+	r := require.New(t)
+
+	file, err := os.Open("testdata/varnishlog_request.txt")
+	r.NoError(err)
+	defer file.Close()
+
+	entries, err := NewRequestParser(file).Parse()
+	r.NoError(err)
+
+	tree := NewTransactionTree(entries)
+	r.Len(tree.Roots, 1)
+	r.Empty(tree.Orphans)
+	r.Empty(tree.DanglingLinks)
+
+	req := tree.Roots[0]
+	r.Equal(KindRequest, req.Entry.Kind)
+	r.Len(req.Children, 1)
+	r.Equal(KindBeReq, req.Children[0].Entry.Kind)
+	r.Equal(ReasonFetch, req.Children[0].Reason)
+
+	var visited []VXID
+	tree.Walk(func(tx *Transaction) bool {
+		visited = append(visited, tx.Entry.VXID)
+		return true
+	})
+	r.Equal([]VXID{2, 3}, visited)
+}
+
+func TestNewTransactionTree_Broken(t *testing.T) {
+	r := require.New(t)
+
+	entries := []Entry{
+		{
+			Level: 1,
+			Kind:  KindRequest,
+			VXID:  10,
+			Tags: []Tag{
+				{"Begin", "req 9 rxreq"},
+				{"Link", "bereq 11 fetch"},
+				{"Link", "req 12 esi"},
+				{"End", ""},
+			},
+		},
+		{
+			Level: 2,
+			Kind:  KindBeReq,
+			VXID:  11,
+			Tags: []Tag{
+				{"Begin", "bereq 10 fetch"},
+				{"End", ""},
+			},
+		},
+		{
+			Level: 3,
+			Kind:  KindBeReq,
+			VXID:  14,
+			Tags: []Tag{
+				{"Begin", "bereq 13 fetch"},
+				{"End", ""},
+			},
+		},
+	}
+
+	tree := NewTransactionTree(entries)
+	r.Len(tree.Roots, 2)
+	r.Equal(VXID(10), tree.Roots[0].Entry.VXID)
+	r.Equal(VXID(14), tree.Roots[1].Entry.VXID)
+
+	r.Len(tree.Orphans, 1)
+	r.Equal(VXID(14), tree.Orphans[0].Entry.VXID)
+
+	r.Len(tree.DanglingLinks, 1)
+	dl := tree.DanglingLinks[0]
+	r.Equal(VXID(12), dl.ChildVXID)
+	r.Equal("req", dl.ChildType)
+	r.Equal(ReasonESI, dl.Reason)
+	r.Same(tree.Roots[0], dl.From)
+}
+
+func TestNewTransactionTree_Cycle(t *testing.T) {
+	r := require.New(t)
+
+	entry := func(level int, vxid VXID, begin string) Entry {
+		return Entry{Level: level, Kind: KindRequest, VXID: vxid, Tags: []Tag{
+			{"Begin", begin},
+			{"End", ""},
+		}}
+	}
+	// 11 and 12 are each other's parents, 13 is a child of 12.
+	entries := []Entry{
+		entry(1, 10, "req 9 rxreq"),
+		entry(3, 13, "req 12 esi"),
+		entry(2, 11, "req 12 esi"),
+		entry(3, 12, "req 11 esi"),
+	}
+
+	tree := NewTransactionTree(entries)
+	r.Len(tree.Roots, 2)
+	r.Equal(VXID(10), tree.Roots[0].Entry.VXID)
+	r.Equal(VXID(11), tree.Roots[1].Entry.VXID)
+	r.Len(tree.Orphans, 1)
+	r.Same(tree.Roots[1], tree.Orphans[0])
+
+	var order []VXID
+	tree.Walk(func(tx *Transaction) bool {
+		order = append(order, tx.Entry.VXID)
+		return true
+	})
+	r.Equal([]VXID{10, 11, 12, 13}, order)
+	r.Nil(tree.Roots[1].Parent)
+	r.Same(tree.Roots[1], tree.Roots[1].Children[0].Parent)
+}
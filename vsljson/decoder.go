@@ -0,0 +1,71 @@
+package vsljson
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/Showmax/vslparser"
+)
+
+// Decoder reads entries written by Encoder in the Lossless mode.
+type Decoder struct {
+	dec *json.Decoder
+}
+
+// NewDecoder creates a new Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{dec: json.NewDecoder(r)}
+}
+
+// Decode reads a single entry written by Encoder.Encode. It returns io.EOF at
+// the end of input.
+func (d *Decoder) Decode() (vslparser.Entry, error) {
+	var j entryJSON
+	if err := d.dec.Decode(&j); err != nil {
+		return vslparser.Entry{}, err
+	}
+	if len(j.Children) > 0 {
+		return vslparser.Entry{}, errors.New("entry has children, use DecodeGroup")
+	}
+	return j.entry(), nil
+}
+
+// DecodeGroup reads a group written by Encoder.EncodeGroup. Nested
+// transactions are flattened in depth-first pre-order, i.e. in the order
+// varnishlog prints them. It returns io.EOF at the end of input.
+func (d *Decoder) DecodeGroup() ([]vslparser.Entry, error) {
+	var roots []*entryJSON
+	if err := d.dec.Decode(&roots); err != nil {
+		return nil, err
+	}
+
+	var entries []vslparser.Entry
+	var walk func(j *entryJSON)
+	walk = func(j *entryJSON) {
+		entries = append(entries, j.entry())
+		for _, c := range j.Children {
+			walk(c)
+		}
+	}
+	for _, root := range roots {
+		walk(root)
+	}
+	return entries, nil
+}
+
+func (j *entryJSON) entry() vslparser.Entry {
+	e := vslparser.Entry{
+		Level:     j.Level,
+		Kind:      j.Kind,
+		VXID:      j.VXID,
+		Truncated: j.Truncated,
+	}
+	if j.Tags != nil {
+		e.Tags = make([]vslparser.Tag, len(j.Tags))
+		for i, tag := range j.Tags {
+			e.Tags[i] = vslparser.Tag{Key: tag[0], Value: tag[1]}
+		}
+	}
+	return e
+}
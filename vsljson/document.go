@@ -0,0 +1,273 @@
+package vsljson
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Showmax/vslparser"
+	"github.com/Showmax/vslparser/vsltag"
+)
+
+// httpSections maps tags describing HTTP messages to the document section
+// and the field they are decoded into.
+var httpSections = map[string]struct{ section, field string }{
+	vslparser.TagReqMethod:      {"req", "method"},
+	vslparser.TagReqURL:         {"req", "url"},
+	vslparser.TagReqProtocol:    {"req", "protocol"},
+	vslparser.TagRespProtocol:   {"resp", "protocol"},
+	vslparser.TagRespStatus:     {"resp", "status"},
+	vslparser.TagRespReason:     {"resp", "reason"},
+	vslparser.TagBeReqMethod:    {"bereq", "method"},
+	vslparser.TagBeReqURL:       {"bereq", "url"},
+	vslparser.TagBeReqProtocol:  {"bereq", "protocol"},
+	vslparser.TagBeRespProtocol: {"beresp", "protocol"},
+	vslparser.TagBerespStatus:   {"beresp", "status"},
+	vslparser.TagBeRespReason:   {"beresp", "reason"},
+}
+
+// headerSections maps header families to document sections.
+var headerSections = []struct {
+	family  vsltag.HeaderFamily
+	section string
+}{
+	{vsltag.ReqHeaders, "req"},
+	{vsltag.RespHeaders, "resp"},
+	{vsltag.BereqHeaders, "bereq"},
+	{vsltag.BerespHeaders, "beresp"},
+}
+
+// document returns the document form of e:
+//
+//	{
+//	  "level": 1, "kind": "Request", "vxid": 32770,
+//	  "begin": {"type": "req", "parent_vxid": 32769, "reason": "rxreq"},
+//	  "client": {"ip": "127.0.0.1", "port": 37980, "listener": "a0"},
+//	  "req": {"method": "PUT", "url": "/foo", "headers": {"Host": [...]}},
+//	  "resp": {"status": 503, "reason": "Backend fetch failed", ...},
+//	  "timestamps": {"Start": {"time": "2022-03-07T22:52:24.293284Z",
+//	    "since_start": 0, "since_last": 0}, ...},
+//	  "acct": {"header_received": 125, ...},
+//	  "links": [{"type": "bereq", "vxid": 32771, "reason": "pass"}],
+//	  "tags": {"VCL_call": ["RECV", "PASS"], ...}
+//	}
+//
+// HTTP fields hold their last logged value and headers their final state after
+// all unset operations. Tags which are logged repeatedly where a single value
+// is expected (e.g. Timestamp events of restarts) and tags which cannot be
+// decoded are kept in "tags" along with all unknown tags.
+func document(e vslparser.Entry) map[string]interface{} {
+	doc := map[string]interface{}{
+		"level": e.Level,
+		"kind":  e.Kind,
+		"vxid":  e.VXID,
+	}
+	if len(e.Truncated) > 0 {
+		doc["truncated"] = true
+	}
+
+	sections := make(map[string]map[string]interface{})
+	section := func(name string) map[string]interface{} {
+		s, ok := sections[name]
+		if !ok {
+			s = make(map[string]interface{})
+			sections[name] = s
+			doc[name] = s
+		}
+		return s
+	}
+	timestamps := make(map[string]interface{})
+	raw := make(map[string][]string)
+
+	for _, tag := range e.Tags {
+		switch tag.Key {
+		case vslparser.TagEnd:
+			continue
+		case vslparser.TagReqHeader, vslparser.TagReqUnset,
+			vslparser.TagRespHeader, vslparser.TagRespUnset,
+			vslparser.TagBeReqHeader, vslparser.TagBeReqUnset,
+			vslparser.TagBeRespHeader, vslparser.TagBeRespUnset:
+			// Folded below.
+			continue
+		}
+
+		if v, ok := decodeTag(tag); ok {
+			switch tag.Key {
+			case vslparser.TagTimestamp:
+				event := vsltag.Timestamp(tag).Event()
+				if _, dup := timestamps[event]; !dup {
+					timestamps[event] = v
+					continue
+				}
+			case vslparser.TagLink:
+				links, _ := doc["links"].([]interface{})
+				doc["links"] = append(links, v)
+				continue
+			default:
+				if hs, ok := httpSections[tag.Key]; ok {
+					section(hs.section)[hs.field] = v
+					continue
+				}
+				key := documentKeys[tag.Key]
+				if _, dup := doc[key]; !dup {
+					doc[key] = v
+					continue
+				}
+			}
+		}
+		raw[tag.Key] = append(raw[tag.Key], tag.Value)
+	}
+
+	for _, hs := range headerSections {
+		if h := vsltag.Headers(e, hs.family); len(h) > 0 {
+			section(hs.section)["headers"] = h
+		}
+	}
+	if len(timestamps) > 0 {
+		doc["timestamps"] = timestamps
+	}
+	if len(raw) > 0 {
+		doc["tags"] = raw
+	}
+	return doc
+}
+
+func documentTree(tx *vslparser.Transaction) map[string]interface{} {
+	doc := document(tx.Entry)
+	if len(tx.Children) > 0 {
+		children := make([]map[string]interface{}, 0, len(tx.Children))
+		for _, c := range tx.Children {
+			children = append(children, documentTree(c))
+		}
+		doc["children"] = children
+	}
+	return doc
+}
+
+// documentKeys maps tags decoded into a single document field to the field.
+var documentKeys = map[string]string{
+	vslparser.TagBegin:     "begin",
+	vslparser.TagReqStart:  "client",
+	vslparser.TagReqAcct:   "acct",
+	vslparser.TagBeReqAcct: "acct",
+	vslparser.TagHit:       "hit",
+}
+
+// decodeTag decodes a well-known tag into a JSON value. The ok result is false
+// for unknown tags and tags which cannot be decoded.
+func decodeTag(tag vslparser.Tag) (v interface{}, ok bool) {
+	if _, ok := httpSections[tag.Key]; ok {
+		if tag.Key == vslparser.TagRespStatus || tag.Key == vslparser.TagBerespStatus {
+			status, err := strconv.Atoi(tag.Value)
+			return status, err == nil
+		}
+		return tag.Value, true
+	}
+
+	switch tag.Key {
+	case vslparser.TagBegin, vslparser.TagLink:
+		fields := strings.Fields(tag.Value)
+		if len(fields) != 3 {
+			return nil, false
+		}
+		vxid, err := strconv.ParseUint(fields[1], 10, 32)
+		if err != nil {
+			return nil, false
+		}
+		vxidKey := "vxid"
+		if tag.Key == vslparser.TagBegin {
+			vxidKey = "parent_vxid"
+		}
+		return map[string]interface{}{
+			"type":   fields[0],
+			vxidKey:  vxid,
+			"reason": fields[2],
+		}, true
+
+	case vslparser.TagTimestamp:
+		if len(strings.Fields(tag.Value)) != 4 {
+			return nil, false
+		}
+		ts := vsltag.Timestamp(tag)
+		t, err := ts.Time()
+		if err != nil {
+			return nil, false
+		}
+		sinceStart, err := ts.SinceStart()
+		if err != nil {
+			return nil, false
+		}
+		sinceLast, err := ts.SinceLast()
+		if err != nil {
+			return nil, false
+		}
+		return map[string]interface{}{
+			"time":        t.UTC().Format(time.RFC3339Nano),
+			"since_start": sinceStart.Seconds(),
+			"since_last":  sinceLast.Seconds(),
+		}, true
+
+	case vslparser.TagReqStart:
+		r := vsltag.ReqStart(tag)
+		ip, err := r.ClientIP()
+		if err != nil {
+			return nil, false
+		}
+		port, err := r.ClientPort()
+		if err != nil {
+			return nil, false
+		}
+		return map[string]interface{}{
+			"ip":       ip.String(),
+			"port":     port,
+			"listener": r.Listener(),
+		}, true
+
+	case vslparser.TagReqAcct, vslparser.TagBeReqAcct:
+		var (
+			c   vsltag.ByteCounts
+			err error
+		)
+		if tag.Key == vslparser.TagReqAcct {
+			c, err = vsltag.ReqAcct(tag).ByteCounts()
+		} else {
+			c, err = vsltag.BereqAcct(tag).ByteCounts()
+		}
+		if err != nil {
+			return nil, false
+		}
+		return map[string]interface{}{
+			"header_received":    c.HeaderReceived,
+			"body_received":      c.BodyReceived,
+			"total_received":     c.TotalReceived,
+			"header_transmitted": c.HeaderTransmitted,
+			"body_transmitted":   c.BodyTransmitted,
+			"total_transmitted":  c.TotalTransmitted,
+		}, true
+
+	case vslparser.TagHit:
+		if len(strings.Fields(tag.Value)) < 4 {
+			return nil, false
+		}
+		h := vsltag.Hit(tag)
+		ttl, err := h.TTL()
+		if err != nil {
+			return nil, false
+		}
+		grace, err := h.Grace()
+		if err != nil {
+			return nil, false
+		}
+		keep, err := h.Keep()
+		if err != nil {
+			return nil, false
+		}
+		return map[string]interface{}{
+			"vxid":  h.VXID(),
+			"ttl":   ttl,
+			"grace": grace,
+			"keep":  keep,
+		}, true
+	}
+	return nil, false
+}
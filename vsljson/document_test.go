@@ -0,0 +1,63 @@
+package vsljson_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/Showmax/vslparser/internal/testlog"
+	"github.com/Showmax/vslparser/vsljson"
+)
+
+func TestDocument(t *testing.T) {
+	group := testlog.RequestGroups(t)[2]
+
+	var buf bytes.Buffer
+	if err := vsljson.NewEncoder(&buf, vsljson.Document).EncodeGroup(group); err != nil {
+		t.Fatalf("EncodeGroup() failed: %v", err)
+	}
+
+	var docs []map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &docs); err != nil {
+		t.Fatalf("cannot unmarshal %s: %v", buf.String(), err)
+	}
+	if len(docs) != 1 {
+		t.Fatalf("EncodeGroup() wrote %d roots, want 1", len(docs))
+	}
+	doc := docs[0]
+
+	check := func(name string, got, want interface{}) {
+		t.Helper()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %#v, want %#v", name, got, want)
+		}
+	}
+	req := doc["req"].(map[string]interface{})
+	resp := doc["resp"].(map[string]interface{})
+	check("vxid", doc["vxid"], 32770.0)
+	check("req.method", req["method"], "PUT")
+	check("req.url", req["url"], "/foo?param=val")
+	check("req.headers.Magic", req["headers"].(map[string]interface{})["Magic"], []interface{}{"aloha"})
+	check("resp.status", resp["status"], 503.0)
+	check("client.port", doc["client"].(map[string]interface{})["port"], 37980.0)
+	check("acct.total_transmitted", doc["acct"].(map[string]interface{})["total_transmitted"], 532.0)
+	check("begin.parent_vxid", doc["begin"].(map[string]interface{})["parent_vxid"], 32769.0)
+
+	start := doc["timestamps"].(map[string]interface{})["Start"].(map[string]interface{})
+	check("timestamps.Start.time", start["time"], "2022-03-07T22:52:24.293284Z")
+
+	tags := doc["tags"].(map[string]interface{})
+	check("tags.VCL_call", tags["VCL_call"], []interface{}{"RECV", "HASH", "PASS", "DELIVER"})
+	if _, ok := tags["End"]; ok {
+		t.Errorf("End tag should be left out")
+	}
+	if _, ok := tags["ReqHeader"]; ok {
+		t.Errorf("ReqHeader tags should be folded")
+	}
+
+	children := doc["children"].([]interface{})
+	bereq := children[0].(map[string]interface{})
+	check("children[0].kind", bereq["kind"], "BeReq")
+	check("children[0].beresp.status", bereq["beresp"].(map[string]interface{})["status"], 503.0)
+}
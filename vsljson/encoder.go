@@ -0,0 +1,106 @@
+// Package vsljson encodes entries parsed by vslparser as JSON, one document
+// per line (NDJSON), and decodes them back.
+//
+// Two forms are supported. The lossless form keeps tags as an ordered list of
+// key-value pairs including duplicates, so that it can be decoded back into
+// identical entries. The document form is meant for log shippers and search
+// engines: headers are folded into objects and well-known tags are decoded
+// into numbers and timestamps.
+package vsljson
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/Showmax/vslparser"
+)
+
+// Mode selects the form of JSON documents written by Encoder.
+type Mode int
+
+const (
+	// Lossless keeps all tags in their order as [key, value] pairs. It is
+	// the only form which can be read by Decoder.
+	Lossless Mode = iota
+	// Document folds headers into objects and decodes well-known tags.
+	// Tags which are not decoded are kept as lists of raw values keyed by
+	// tag name.
+	Document
+)
+
+// entryJSON is the lossless form of an Entry.
+type entryJSON struct {
+	Level     int            `json:"level"`
+	Kind      string         `json:"kind"`
+	VXID      vslparser.VXID `json:"vxid"`
+	Tags      [][2]string    `json:"tags"`
+	Truncated []int          `json:"truncated,omitempty"`
+	Children  []*entryJSON   `json:"children,omitempty"`
+}
+
+// Encoder writes entries as JSON documents, one per line.
+type Encoder struct {
+	enc  *json.Encoder
+	mode Mode
+}
+
+// NewEncoder creates a new Encoder writing documents of the given mode to w.
+func NewEncoder(w io.Writer, mode Mode) *Encoder {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &Encoder{enc: enc, mode: mode}
+}
+
+// Encode writes a single entry as a JSON object.
+func (enc *Encoder) Encode(e vslparser.Entry) error {
+	if enc.mode == Document {
+		return enc.enc.Encode(document(e))
+	}
+	return enc.enc.Encode(lossless(e))
+}
+
+// EncodeGroup writes a request or session group as a JSON array of the root
+// transactions of the group. Child transactions, e.g. BeReqs or ESI
+// sub-requests, are nested in the "children" field of their parents following
+// vslparser.TransactionTree.
+func (enc *Encoder) EncodeGroup(entries []vslparser.Entry) error {
+	tree := vslparser.NewTransactionTree(entries)
+	if enc.mode == Document {
+		docs := make([]map[string]interface{}, 0, len(tree.Roots))
+		for _, root := range tree.Roots {
+			docs = append(docs, documentTree(root))
+		}
+		return enc.enc.Encode(docs)
+	}
+
+	roots := make([]*entryJSON, 0, len(tree.Roots))
+	for _, root := range tree.Roots {
+		roots = append(roots, losslessTree(root))
+	}
+	return enc.enc.Encode(roots)
+}
+
+func lossless(e vslparser.Entry) *entryJSON {
+	var tags [][2]string
+	if e.Tags != nil {
+		tags = make([][2]string, len(e.Tags))
+		for i, tag := range e.Tags {
+			tags[i] = [2]string{tag.Key, tag.Value}
+		}
+	}
+	return &entryJSON{
+		Level:     e.Level,
+		Kind:      e.Kind,
+		VXID:      e.VXID,
+		Tags:      tags,
+		Truncated: e.Truncated,
+	}
+}
+
+func losslessTree(tx *vslparser.Transaction) *entryJSON {
+	j := lossless(tx.Entry)
+	for _, c := range tx.Children {
+		j.Children = append(j.Children, losslessTree(c))
+	}
+	return j
+}
@@ -0,0 +1,127 @@
+package vsljson_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	vsl "github.com/Showmax/vslparser"
+	"github.com/Showmax/vslparser/internal/testlog"
+	"github.com/Showmax/vslparser/vsljson"
+)
+
+func TestLossless_RoundTrip(t *testing.T) {
+	groups := testlog.RequestGroups(t)
+
+	var buf bytes.Buffer
+	enc := vsljson.NewEncoder(&buf, vsljson.Lossless)
+	for _, g := range groups {
+		if err := enc.EncodeGroup(g); err != nil {
+			t.Fatalf("EncodeGroup() failed: %v", err)
+		}
+	}
+	if n := strings.Count(buf.String(), "\n"); n != len(groups) {
+		t.Errorf("EncodeGroup() wrote %d lines, want %d", n, len(groups))
+	}
+	if !strings.Contains(buf.String(), `"children":[{"level":2,"kind":"BeReq","vxid":3,`) {
+		t.Errorf("BeReq is not nested in its parent request: %s", buf.String())
+	}
+
+	dec := vsljson.NewDecoder(&buf)
+	for i, want := range groups {
+		got, err := dec.DecodeGroup()
+		if err != nil {
+			t.Fatalf("DecodeGroup() failed: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("group %d = %+v, want %+v", i, got, want)
+		}
+	}
+	if _, err := dec.DecodeGroup(); err != io.EOF {
+		t.Errorf("DecodeGroup() at the end of input = %v, want io.EOF", err)
+	}
+}
+
+func TestEncodeGroup_Cycle(t *testing.T) {
+	entry := func(level int, vxid vsl.VXID, begin string) vsl.Entry {
+		return vsl.Entry{Level: level, Kind: vsl.KindRequest, VXID: vxid, Tags: []vsl.Tag{
+			{Key: "Begin", Value: begin},
+			{Key: "End", Value: ""},
+		}}
+	}
+	root := entry(1, 1, "req 0 rxreq")
+	// 10 and 11 are each other's parents, 5 has its parent missing.
+	cycle1, cycle2 := entry(2, 10, "req 11 esi"), entry(3, 11, "req 10 esi")
+	orphan := entry(2, 5, "req 4 esi")
+	group := []vsl.Entry{root, cycle1, orphan, cycle2}
+
+	var buf bytes.Buffer
+	if err := vsljson.NewEncoder(&buf, vsljson.Lossless).EncodeGroup(group); err != nil {
+		t.Fatalf("EncodeGroup() failed: %v", err)
+	}
+	got, err := vsljson.NewDecoder(&buf).DecodeGroup()
+	if err != nil {
+		t.Fatalf("DecodeGroup() failed: %v", err)
+	}
+	if want := []vsl.Entry{root, cycle1, cycle2, orphan}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DecodeGroup() = %+v, want %+v", got, want)
+	}
+
+	buf.Reset()
+	if err := vsljson.NewEncoder(&buf, vsljson.Document).EncodeGroup(group); err != nil {
+		t.Fatalf("EncodeGroup() failed: %v", err)
+	}
+	var docs []struct {
+		VXID     vsl.VXID `json:"vxid"`
+		Children []struct {
+			VXID vsl.VXID `json:"vxid"`
+		} `json:"children"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &docs); err != nil {
+		t.Fatalf("cannot unmarshal documents: %v", err)
+	}
+	if len(docs) != 3 || docs[0].VXID != 1 || docs[1].VXID != 10 || docs[2].VXID != 5 ||
+		len(docs[1].Children) != 1 || docs[1].Children[0].VXID != 11 {
+		t.Errorf("EncodeGroup() wrote documents %+v, want VXIDs 1, 10 with child 11, 5", docs)
+	}
+}
+
+func TestLossless_Entry(t *testing.T) {
+	want := vsl.Entry{
+		Level: 1,
+		Kind:  vsl.KindRequest,
+		VXID:  7,
+		Tags: []vsl.Tag{
+			{Key: "ReqHeader", Value: "X-A: 1"},
+			{Key: "ReqHeader", Value: "X-A: 1"},
+			{Key: "ReqURL", Value: "/<script>&"},
+			{Key: "End", Value: ""},
+		},
+		Truncated: []int{2},
+	}
+
+	var buf bytes.Buffer
+	if err := vsljson.NewEncoder(&buf, vsljson.Lossless).Encode(want); err != nil {
+		t.Fatalf("Encode() failed: %v", err)
+	}
+	const line = `{"level":1,"kind":"Request","vxid":7,"tags":[["ReqHeader","X-A: 1"],["ReqHeader","X-A: 1"],["ReqURL","/<script>&"],["End",""]],"truncated":[2]}` + "\n"
+	if buf.String() != line {
+		t.Errorf("Encode() = %s, want %s", buf.String(), line)
+	}
+
+	got, err := vsljson.NewDecoder(&buf).Decode()
+	if err != nil {
+		t.Fatalf("Decode() failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode() = %+v, want %+v", got, want)
+	}
+
+	_, err = vsljson.NewDecoder(strings.NewReader(`{"children":[{}]}`)).Decode()
+	if err == nil {
+		t.Errorf("Decode() of an entry with children should fail")
+	}
+}
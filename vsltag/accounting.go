@@ -0,0 +1,133 @@
+package vsltag
+
+import (
+	"fmt"
+
+	"github.com/Showmax/vslparser"
+)
+
+// ReqAcct stands for Request handling byte counts. It holds byte counts of
+// the request received from and the response transmitted to the client.
+type ReqAcct vslparser.Tag
+
+func (r ReqAcct) HeaderBytesReceived() (int64, error)    { return int64Field(r.Value, 0) }
+func (r ReqAcct) BodyBytesReceived() (int64, error)      { return int64Field(r.Value, 1) }
+func (r ReqAcct) TotalBytesReceived() (int64, error)     { return int64Field(r.Value, 2) }
+func (r ReqAcct) HeaderBytesTransmitted() (int64, error) { return int64Field(r.Value, 3) }
+func (r ReqAcct) BodyBytesTransmitted() (int64, error)   { return int64Field(r.Value, 4) }
+func (r ReqAcct) TotalBytesTransmitted() (int64, error)  { return int64Field(r.Value, 5) }
+
+// ByteCounts returns all the columns at once.
+func (r ReqAcct) ByteCounts() (ByteCounts, error) {
+	return parseByteCounts(r.Value, 0, 3)
+}
+
+// BereqAcct stands for Backend request accounting. It holds byte counts of
+// the request transmitted to and the response received from the backend.
+// Unlike ReqAcct, transmitted bytes are logged first.
+type BereqAcct vslparser.Tag
+
+func (b BereqAcct) HeaderBytesTransmitted() (int64, error) { return int64Field(b.Value, 0) }
+func (b BereqAcct) BodyBytesTransmitted() (int64, error)   { return int64Field(b.Value, 1) }
+func (b BereqAcct) TotalBytesTransmitted() (int64, error)  { return int64Field(b.Value, 2) }
+func (b BereqAcct) HeaderBytesReceived() (int64, error)    { return int64Field(b.Value, 3) }
+func (b BereqAcct) BodyBytesReceived() (int64, error)      { return int64Field(b.Value, 4) }
+func (b BereqAcct) TotalBytesReceived() (int64, error)     { return int64Field(b.Value, 5) }
+
+// ByteCounts returns all the columns at once.
+func (b BereqAcct) ByteCounts() (ByteCounts, error) {
+	return parseByteCounts(b.Value, 3, 0)
+}
+
+// ByteCounts holds byte counts logged by ReqAcct or BereqAcct from the point
+// of view of Varnish, i.e. received bytes are the request for ReqAcct and the
+// response for BereqAcct.
+type ByteCounts struct {
+	HeaderReceived    int64
+	BodyReceived      int64
+	TotalReceived     int64
+	HeaderTransmitted int64
+	BodyTransmitted   int64
+	TotalTransmitted  int64
+}
+
+// Add adds counts of o to c.
+func (c *ByteCounts) Add(o ByteCounts) {
+	c.HeaderReceived += o.HeaderReceived
+	c.BodyReceived += o.BodyReceived
+	c.TotalReceived += o.TotalReceived
+	c.HeaderTransmitted += o.HeaderTransmitted
+	c.BodyTransmitted += o.BodyTransmitted
+	c.TotalTransmitted += o.TotalTransmitted
+}
+
+// parseByteCounts parses six columns of s, received columns starting at index
+// rx and transmitted columns starting at index tx.
+func parseByteCounts(s string, rx, tx int) (ByteCounts, error) {
+	var c ByteCounts
+	columns := []struct {
+		dst *int64
+		i   int
+	}{
+		{&c.HeaderReceived, rx},
+		{&c.BodyReceived, rx + 1},
+		{&c.TotalReceived, rx + 2},
+		{&c.HeaderTransmitted, tx},
+		{&c.BodyTransmitted, tx + 1},
+		{&c.TotalTransmitted, tx + 2},
+	}
+	for _, col := range columns {
+		v, err := int64Field(s, col.i)
+		if err != nil {
+			return ByteCounts{}, err
+		}
+		*col.dst = v
+	}
+	return c, nil
+}
+
+// Accounting holds byte counts summed across a request group.
+type Accounting struct {
+	// Client is the sum of ReqAcct tags, i.e. traffic between clients and
+	// Varnish.
+	Client ByteCounts
+	// Backend is the sum of BereqAcct tags, i.e. traffic between Varnish
+	// and backends.
+	Backend ByteCounts
+}
+
+// SumAccounting sums ReqAcct and BereqAcct tags of all entries, e.g. of
+// a request group. Body bytes delivered by ESI subrequests are included in
+// ReqAcct of the request which delivered them to the client, so ReqAcct tags
+// of ESI subrequests are skipped to not count the bytes twice.
+func SumAccounting(entries []vslparser.Entry) (Accounting, error) {
+	var acct Accounting
+	for _, e := range entries {
+		esi := isESI(e)
+		for _, tag := range e.Tags {
+			var (
+				c   ByteCounts
+				sum *ByteCounts
+				err error
+			)
+			switch tag.Key {
+			case vslparser.TagReqAcct:
+				if esi {
+					continue
+				}
+				c, err = ReqAcct(tag).ByteCounts()
+				sum = &acct.Client
+			case vslparser.TagBeReqAcct:
+				c, err = BereqAcct(tag).ByteCounts()
+				sum = &acct.Backend
+			default:
+				continue
+			}
+			if err != nil {
+				return Accounting{}, fmt.Errorf("invalid %s of VXID %d: %w", tag.Key, e.VXID, err)
+			}
+			sum.Add(c)
+		}
+	}
+	return acct, nil
+}
@@ -0,0 +1,134 @@
+package vsltag_test
+
+import (
+	"errors"
+	"testing"
+
+	vsl "github.com/Showmax/vslparser"
+	"github.com/Showmax/vslparser/vsltag"
+)
+
+func TestReqAcct(t *testing.T) {
+	acct := vsltag.ReqAcct{Key: "ReqAcct", Value: "82 0 82 304 6962 7266"}
+
+	getters := []struct {
+		name string
+		get  func() (int64, error)
+		want int64
+	}{
+		{"HeaderBytesReceived", acct.HeaderBytesReceived, 82},
+		{"BodyBytesReceived", acct.BodyBytesReceived, 0},
+		{"TotalBytesReceived", acct.TotalBytesReceived, 82},
+		{"HeaderBytesTransmitted", acct.HeaderBytesTransmitted, 304},
+		{"BodyBytesTransmitted", acct.BodyBytesTransmitted, 6962},
+		{"TotalBytesTransmitted", acct.TotalBytesTransmitted, 7266},
+	}
+	for _, g := range getters {
+		if got, err := g.get(); err != nil || got != g.want {
+			t.Errorf("%s() = %d, %v, want %d", g.name, got, err, g.want)
+		}
+	}
+
+	bad := vsltag.ReqAcct{Key: "ReqAcct", Value: "82 x 82 304"}
+	if _, err := bad.BodyBytesReceived(); err == nil {
+		t.Errorf("BodyBytesReceived() of malformed value should fail")
+	}
+	if _, err := bad.TotalBytesTransmitted(); !errors.Is(err, vsltag.ErrMissingField) {
+		t.Errorf("TotalBytesTransmitted() error = %v, want ErrMissingField", err)
+	}
+}
+
+func TestBereqAcct(t *testing.T) {
+	got, err := vsltag.BereqAcct{Key: "BereqAcct", Value: "301 0 301 277 6962 7239"}.ByteCounts()
+	want := vsltag.ByteCounts{
+		HeaderReceived:    277,
+		BodyReceived:      6962,
+		TotalReceived:     7239,
+		HeaderTransmitted: 301,
+		BodyTransmitted:   0,
+		TotalTransmitted:  301,
+	}
+	if err != nil || got != want {
+		t.Errorf("ByteCounts() = %+v, %v, want %+v", got, err, want)
+	}
+}
+
+func TestSumAccounting(t *testing.T) {
+	entries := []vsl.Entry{
+		{VXID: 2, Kind: vsl.KindRequest, Tags: []vsl.Tag{
+			{Key: "ReqAcct", Value: "82 0 82 304 6962 7266"},
+		}},
+		{VXID: 3, Kind: vsl.KindBeReq, Tags: []vsl.Tag{
+			{Key: "BereqAcct", Value: "301 0 301 277 6962 7239"},
+		}},
+		{VXID: 4, Kind: vsl.KindRequest, Tags: []vsl.Tag{
+			{Key: "ReqAcct", Value: "100 10 110 200 0 200"},
+		}},
+	}
+
+	got, err := vsltag.SumAccounting(entries)
+	if err != nil {
+		t.Fatalf("SumAccounting() failed: %v", err)
+	}
+	want := vsltag.Accounting{
+		Client: vsltag.ByteCounts{
+			HeaderReceived:    182,
+			BodyReceived:      10,
+			TotalReceived:     192,
+			HeaderTransmitted: 504,
+			BodyTransmitted:   6962,
+			TotalTransmitted:  7466,
+		},
+		Backend: vsltag.ByteCounts{
+			HeaderReceived:    277,
+			BodyReceived:      6962,
+			TotalReceived:     7239,
+			HeaderTransmitted: 301,
+			TotalTransmitted:  301,
+		},
+	}
+	if got != want {
+		t.Errorf("SumAccounting() = %+v, want %+v", got, want)
+	}
+
+	entries[2].Tags[0].Value = "100 10 110"
+	if _, err := vsltag.SumAccounting(entries); !errors.Is(err, vsltag.ErrMissingField) {
+		t.Errorf("SumAccounting() error = %v, want ErrMissingField", err)
+	}
+}
+
+func TestSumAccounting_ESI(t *testing.T) {
+	// The client received the page including both fragments, the body bytes
+	// of the ESI subrequests are part of ReqAcct of the top request.
+	entries := []vsl.Entry{
+		entry(1, vsl.KindRequest, 2,
+			tag("Begin", "req 1 rxreq"),
+			tag("Link", "req 3 esi"),
+			tag("ReqAcct", "80 0 80 200 1000 1200"),
+		),
+		entry(2, vsl.KindRequest, 3,
+			tag("Begin", "req 2 esi"),
+			tag("Link", "req 4 esi"),
+			tag("ReqAcct", "0 0 0 0 600 600"),
+		),
+		entry(3, vsl.KindRequest, 4,
+			tag("Begin", "req 3 esi"),
+			tag("ReqAcct", "0 0 0 0 100 100"),
+		),
+	}
+
+	got, err := vsltag.SumAccounting(entries)
+	if err != nil {
+		t.Fatalf("SumAccounting() failed: %v", err)
+	}
+	want := vsltag.ByteCounts{
+		HeaderReceived:    80,
+		TotalReceived:     80,
+		HeaderTransmitted: 200,
+		BodyTransmitted:   1000,
+		TotalTransmitted:  1200,
+	}
+	if got.Client != want {
+		t.Errorf("SumAccounting().Client = %+v, want %+v", got.Client, want)
+	}
+}
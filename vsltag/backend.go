@@ -0,0 +1,258 @@
+package vsltag
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Showmax/vslparser"
+)
+
+// Reason returns how the connection was obtained, "connect" for a new
+// connection or "reuse" for a connection taken from the pool. It is empty for
+// Varnish versions which don't log it.
+func (l BackendOpen) Reason() string {
+	f, _ := field(l.Value, 6)
+	return f
+}
+
+// BackendReuse stands for Backend connection put up for reuse. Logged by
+// older Varnish versions when a backend connection is returned to the pool.
+type BackendReuse vslparser.Tag
+
+func (b BackendReuse) FileDescriptor() (int, error) { return intField(b.Value, 0) }
+func (b BackendReuse) Name() (string, error)        { return field(b.Value, 1) }
+
+// BackendClose stands for Backend connection closed. Logged when a backend
+// connection is closed or, with reason "recycle", returned to the pool.
+type BackendClose vslparser.Tag
+
+func (b BackendClose) FileDescriptor() (int, error) { return intField(b.Value, 0) }
+func (b BackendClose) Name() (string, error)        { return field(b.Value, 1) }
+
+// Reason returns the reason of closing, e.g. "close" or "recycle". It is
+// empty if it wasn't logged.
+func (b BackendClose) Reason() string {
+	sp := strings.SplitN(b.Value, " ", 3)
+	if len(sp) < 3 {
+		return ""
+	}
+	return sp[2]
+}
+
+// BackendStart stands for Backend request start. Start of backend processing,
+// it holds the backend address.
+type BackendStart vslparser.Tag
+
+func (b BackendStart) RemoteAddr() (addr net.IP, port int, err error) {
+	f, err := field(b.Value, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	if addr = net.ParseIP(f); addr == nil {
+		return nil, 0, fmt.Errorf("invalid IP address %q", f)
+	}
+	port, err = intField(b.Value, 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	return addr, port, nil
+}
+
+// BackendHealth stands for Backend health check. It is a non-transactional
+// record logged for every health probe, e.g.
+//
+//	boot.default Still healthy 4---X-RH 5 3 5 0.001037 0.001109 HTTP/1.1 200 OK
+type BackendHealth vslparser.Tag
+
+func (b BackendHealth) Name() (string, error) { return field(b.Value, 0) }
+
+// Transition returns "Still", "Back" or "Went" telling whether the health
+// state changed.
+func (b BackendHealth) Transition() (string, error) { return field(b.Value, 1) }
+
+// State returns "healthy" or "sick".
+func (b BackendHealth) State() (string, error) { return field(b.Value, 2) }
+
+// Healthy reports whether the backend is healthy after the probe.
+func (b BackendHealth) Healthy() bool {
+	state, err := b.State()
+	return err == nil && state == "healthy"
+}
+
+// Bits returns the probe result flags, e.g. "4---X-RH".
+func (b BackendHealth) Bits() (string, error) { return field(b.Value, 3) }
+
+// Good returns the number of good probes in the window.
+func (b BackendHealth) Good() (int, error)      { return intField(b.Value, 4) }
+func (b BackendHealth) Threshold() (int, error) { return intField(b.Value, 5) }
+func (b BackendHealth) Window() (int, error)    { return intField(b.Value, 6) }
+
+// RTT returns the round trip time of the probe.
+func (b BackendHealth) RTT() (time.Duration, error) { return durationField(b.Value, 7) }
+
+// Average returns the average round trip time of good probes.
+func (b BackendHealth) Average() (time.Duration, error) { return durationField(b.Value, 8) }
+
+// Response returns the status line of the probe response, if any.
+func (b BackendHealth) Response() string {
+	sp := strings.SplitN(b.Value, " ", 10)
+	if len(sp) < 10 {
+		return ""
+	}
+	return strings.Trim(sp[9], `"`)
+}
+
+// BackendConn is a single backend connection followed by ConnTracker.
+type BackendConn struct {
+	// Backend is the name of the backend, e.g. "boot.default".
+	Backend string
+	// FD is the file descriptor of the connection.
+	FD int
+	// RemoteAddr and LocalAddr are the endpoints of the connection in
+	// host:port form. They are empty if the connection was opened before
+	// tracking started.
+	RemoteAddr, LocalAddr string
+	// Uses are VXIDs of BeReqs which used the connection, the first one
+	// opened it.
+	Uses []vslparser.VXID
+	// Recycles is the number of times the connection was returned to the
+	// pool.
+	Recycles int
+	// Closed tells whether the connection was closed and CloseReason is
+	// the logged reason.
+	Closed      bool
+	CloseReason string
+
+	// idle tells whether the connection is in the pool.
+	idle bool
+}
+
+// connKey identifies an open backend connection.
+type connKey struct {
+	backend string
+	fd      int
+}
+
+// ConnTracker follows backend connections from BackendOpen through reuses to
+// BackendClose. File descriptors are recycled by the operating system, so
+// a connection is identified by its backend and file descriptor only while
+// it is open.
+type ConnTracker struct {
+	open  map[connKey]*BackendConn
+	conns []*BackendConn
+}
+
+// NewConnTracker creates an empty ConnTracker.
+func NewConnTracker() *ConnTracker {
+	return &ConnTracker{open: make(map[connKey]*BackendConn)}
+}
+
+// Add processes backend connection tags of a BeReq entry. Entries must be
+// added in the order they were logged.
+func (t *ConnTracker) Add(e vslparser.Entry) {
+	for _, tag := range e.Tags {
+		switch tag.Key {
+		case vslparser.TagBackendOpen:
+			t.opened(e.VXID, BackendOpen(tag))
+		case vslparser.TagBackendReuse:
+			r := BackendReuse(tag)
+			fd, err1 := r.FileDescriptor()
+			name, err2 := r.Name()
+			if err1 == nil && err2 == nil {
+				t.recycled(connKey{name, fd})
+			}
+		case vslparser.TagBackendClose:
+			c := BackendClose(tag)
+			fd, err1 := c.FileDescriptor()
+			name, err2 := c.Name()
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			if c.Reason() == "recycle" {
+				t.recycled(connKey{name, fd})
+			} else {
+				t.closed(connKey{name, fd}, c.Reason())
+			}
+		}
+	}
+}
+
+func (t *ConnTracker) opened(vxid vslparser.VXID, o BackendOpen) {
+	fd, err1 := intField(o.Value, 0)
+	name, err2 := field(o.Value, 1)
+	if err1 != nil || err2 != nil {
+		return
+	}
+	key := connKey{name, fd}
+
+	conn, ok := t.open[key]
+	reuse := o.Reason() == "reuse" || (o.Reason() == "" && ok && conn.idle)
+	if !reuse && ok {
+		// Close of the previous connection was not logged.
+		t.closed(key, "")
+		ok = false
+	}
+	if !ok {
+		conn = &BackendConn{Backend: name, FD: fd}
+		if len(strings.Fields(o.Value)) >= 6 {
+			raddr, rport := o.RemoteAddr()
+			laddr, lport := o.LocalAddr()
+			conn.RemoteAddr = net.JoinHostPort(raddr.String(), strconv.Itoa(rport))
+			conn.LocalAddr = net.JoinHostPort(laddr.String(), strconv.Itoa(lport))
+		}
+		t.open[key] = conn
+		t.conns = append(t.conns, conn)
+	}
+	conn.Uses = append(conn.Uses, vxid)
+	conn.idle = false
+}
+
+func (t *ConnTracker) recycled(key connKey) {
+	conn, ok := t.open[key]
+	if !ok {
+		conn = &BackendConn{Backend: key.backend, FD: key.fd}
+		t.open[key] = conn
+		t.conns = append(t.conns, conn)
+	}
+	conn.Recycles++
+	conn.idle = true
+}
+
+func (t *ConnTracker) closed(key connKey, reason string) {
+	conn, ok := t.open[key]
+	if !ok {
+		conn = &BackendConn{Backend: key.backend, FD: key.fd}
+		t.conns = append(t.conns, conn)
+	}
+	conn.Closed = true
+	conn.CloseReason = reason
+	conn.idle = false
+	delete(t.open, key)
+}
+
+// Conns returns connections of backend in the order they were opened, or all
+// connections if backend is empty.
+func (t *ConnTracker) Conns(backend string) []*BackendConn {
+	var conns []*BackendConn
+	for _, c := range t.conns {
+		if backend == "" || c.Backend == backend {
+			conns = append(conns, c)
+		}
+	}
+	return conns
+}
+
+// Open returns connections of backend which are open, either in use or idle
+// in the pool, or of all backends if backend is empty.
+func (t *ConnTracker) Open(backend string) []*BackendConn {
+	var conns []*BackendConn
+	for _, c := range t.conns {
+		if !c.Closed && (backend == "" || c.Backend == backend) {
+			conns = append(conns, c)
+		}
+	}
+	return conns
+}
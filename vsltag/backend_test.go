@@ -0,0 +1,128 @@
+package vsltag_test
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	vsl "github.com/Showmax/vslparser"
+	"github.com/Showmax/vslparser/vsltag"
+)
+
+func TestBackendClose(t *testing.T) {
+	c := vsltag.BackendClose{Key: "BackendClose", Value: "26 boot.default recycle"}
+	if fd, err := c.FileDescriptor(); err != nil || fd != 26 {
+		t.Errorf("FileDescriptor() = %d, %v, want 26", fd, err)
+	}
+	if name, err := c.Name(); err != nil || name != "boot.default" {
+		t.Errorf("Name() = %q, %v, want boot.default", name, err)
+	}
+	if got := c.Reason(); got != "recycle" {
+		t.Errorf("Reason() = %q, want recycle", got)
+	}
+
+	c = vsltag.BackendClose{Key: "BackendClose", Value: "x boot.default"}
+	if _, err := c.FileDescriptor(); err == nil {
+		t.Errorf("FileDescriptor() of malformed value should fail")
+	}
+	if got := c.Reason(); got != "" {
+		t.Errorf("Reason() = %q, want empty", got)
+	}
+}
+
+func TestBackendStart(t *testing.T) {
+	s := vsltag.BackendStart{Key: "BackendStart", Value: "127.0.0.1 8080"}
+	if addr, port, err := s.RemoteAddr(); err != nil || !addr.Equal(net.IPv4(127, 0, 0, 1)) || port != 8080 {
+		t.Errorf("RemoteAddr() = %v, %d, %v, want 127.0.0.1, 8080", addr, port, err)
+	}
+}
+
+func TestBackendHealth(t *testing.T) {
+	h := vsltag.BackendHealth{
+		Key:   "Backend_health",
+		Value: "boot.default Went sick 4---X-R- 2 3 5 0.001037 0.001109 HTTP/1.1 503 Service Unavailable",
+	}
+	if name, err := h.Name(); err != nil || name != "boot.default" {
+		t.Errorf("Name() = %q, %v, want boot.default", name, err)
+	}
+	if tr, err := h.Transition(); err != nil || tr != "Went" {
+		t.Errorf("Transition() = %q, %v, want Went", tr, err)
+	}
+	if h.Healthy() {
+		t.Errorf("Healthy() = true, want false")
+	}
+	if bits, err := h.Bits(); err != nil || bits != "4---X-R-" {
+		t.Errorf("Bits() = %q, %v, want 4---X-R-", bits, err)
+	}
+	for _, tt := range []struct {
+		name string
+		get  func() (int, error)
+		want int
+	}{
+		{"Good", h.Good, 2},
+		{"Threshold", h.Threshold, 3},
+		{"Window", h.Window, 5},
+	} {
+		if got, err := tt.get(); err != nil || got != tt.want {
+			t.Errorf("%s() = %d, %v, want %d", tt.name, got, err, tt.want)
+		}
+	}
+	if rtt, err := h.RTT(); err != nil || rtt != 1037*time.Microsecond {
+		t.Errorf("RTT() = %v, %v, want 1.037ms", rtt, err)
+	}
+	if avg, err := h.Average(); err != nil || avg != 1109*time.Microsecond {
+		t.Errorf("Average() = %v, %v, want 1.109ms", avg, err)
+	}
+	if got := h.Response(); got != "HTTP/1.1 503 Service Unavailable" {
+		t.Errorf("Response() = %q", got)
+	}
+}
+
+func bereqWith(vxid vsl.VXID, tags ...vsl.Tag) vsl.Entry {
+	return vsl.Entry{Level: 1, Kind: vsl.KindBeReq, VXID: vxid, Tags: tags}
+}
+
+func TestConnTracker(t *testing.T) {
+	tracker := vsltag.NewConnTracker()
+	for _, e := range []vsl.Entry{
+		bereqWith(3,
+			vsl.Tag{Key: "BackendOpen", Value: "26 default 127.0.0.1 8080 127.0.0.1 45678 connect"},
+			vsl.Tag{Key: "BackendClose", Value: "26 default recycle"},
+		),
+		bereqWith(5,
+			vsl.Tag{Key: "BackendOpen", Value: "26 default 127.0.0.1 8080 127.0.0.1 45678 reuse"},
+			vsl.Tag{Key: "BackendClose", Value: "26 default close"},
+		),
+		// Older Varnish versions.
+		bereqWith(7,
+			vsl.Tag{Key: "BackendOpen", Value: "26 other 127.0.0.2 8080 127.0.0.1 45700"},
+			vsl.Tag{Key: "BackendReuse", Value: "26 other"},
+		),
+		bereqWith(9,
+			vsl.Tag{Key: "BackendOpen", Value: "26 other 127.0.0.2 8080 127.0.0.1 45700"},
+		),
+	} {
+		tracker.Add(e)
+	}
+
+	conns := tracker.Conns("default")
+	if len(conns) != 1 {
+		t.Fatalf("Conns(default) = %d connections, want 1", len(conns))
+	}
+	c := conns[0]
+	if c.FD != 26 || c.RemoteAddr != "127.0.0.1:8080" || c.LocalAddr != "127.0.0.1:45678" {
+		t.Errorf("unexpected connection %+v", c)
+	}
+	if !reflect.DeepEqual(c.Uses, []vsl.VXID{3, 5}) || c.Recycles != 1 || !c.Closed || c.CloseReason != "close" {
+		t.Errorf("unexpected connection lifecycle %+v", c)
+	}
+
+	open := tracker.Open("")
+	if len(open) != 1 || open[0].Backend != "other" || !reflect.DeepEqual(open[0].Uses, []vsl.VXID{7, 9}) {
+		t.Errorf("Open() = %+v, want single connection of other used by 7 and 9", open)
+	}
+	if all := tracker.Conns(""); len(all) != 2 {
+		t.Errorf("Conns() = %d connections, want 2", len(all))
+	}
+}
@@ -0,0 +1,207 @@
+package vsltag
+
+import (
+	"strings"
+
+	"github.com/Showmax/vslparser"
+)
+
+// Outcome is the cache decision made for a client request.
+type Outcome int
+
+const (
+	// OutcomeUnknown means that no decision was logged, e.g. because the
+	// request failed before VCL was called.
+	OutcomeUnknown Outcome = iota
+	// OutcomeHit means that the response was delivered from a fresh
+	// cached object.
+	OutcomeHit
+	// OutcomeGraceHit means that the response was delivered from a stale
+	// cached object in grace, while it was refreshed by a background fetch.
+	OutcomeGraceHit
+	// OutcomeMiss means that the object was not found in cache and was
+	// fetched from the backend.
+	OutcomeMiss
+	// OutcomePass means that the request was passed to the backend by VCL.
+	OutcomePass
+	// OutcomeHitPass means that the request was passed to the backend
+	// because of a hit-for-pass object.
+	OutcomeHitPass
+	// OutcomeHitMiss means that the request was handled as a miss because
+	// of a hit-for-miss object.
+	OutcomeHitMiss
+	// OutcomePipe means that the connection was piped to the backend.
+	OutcomePipe
+	// OutcomeSynth means that the response was synthesized by VCL.
+	OutcomeSynth
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeHit:
+		return "hit"
+	case OutcomeGraceHit:
+		return "grace hit"
+	case OutcomeMiss:
+		return "miss"
+	case OutcomePass:
+		return "pass"
+	case OutcomeHitPass:
+		return "hitpass"
+	case OutcomeHitMiss:
+		return "hitmiss"
+	case OutcomePipe:
+		return "pipe"
+	case OutcomeSynth:
+		return "synth"
+	}
+	return "unknown"
+}
+
+// CacheResult describes the cache decision made for a client request.
+type CacheResult struct {
+	Outcome Outcome
+	// ObjectVXID is VXID of the transaction which fetched the cached
+	// object the request hit (including hit-for-pass and hit-for-miss
+	// objects). It is 0 if no object was hit.
+	ObjectVXID vslparser.VXID
+	// BeReqVXID is VXID of the backend request started by the request
+	// (fetch, pass or background fetch). It is 0 if there was none.
+	BeReqVXID vslparser.VXID
+}
+
+// CacheOutcome classifies the cache decision of the client request in
+// entries, e.g. a request group. The first client request is examined; if it
+// was restarted, the decision of the last restart is returned.
+//
+// Like varnishncsa, the last VCL_call of HIT, MISS, PASS, PIPE or SYNTH
+// decides. Hit, HitMiss and HitPass tags further tell hits of stale objects
+// and hit-for-miss and hit-for-pass objects apart. A hit is a grace hit if the
+// remaining TTL of the object was negative or if the request started
+// a background fetch.
+func CacheOutcome(entries []vslparser.Entry) CacheResult {
+	e, ok := lastRestart(entries)
+	if !ok {
+		return CacheResult{}
+	}
+
+	var (
+		res                              CacheResult
+		call                             string
+		hitMiss, hitPass, grace, bgfetch bool
+		hitVXID                          vslparser.VXID
+	)
+	for _, tag := range e.Tags {
+		switch tag.Key {
+		case vslparser.TagVCLCall:
+			switch tag.Value {
+			case "HIT", "MISS", "PASS", "PIPE", "SYNTH":
+				call = tag.Value
+			}
+		case vslparser.TagHit:
+			h := Hit(tag)
+			hitVXID = h.VXID()
+			// Older Varnish versions log the VXID only.
+			if len(strings.Fields(h.Value)) < 2 {
+				continue
+			}
+			if ttl, err := h.TTL(); err == nil && ttl < 0 {
+				grace = true
+			}
+		case vslparser.TagHitMiss:
+			hitMiss, hitVXID = true, HitMiss(tag).VXID()
+		case vslparser.TagHitPass:
+			hitPass, hitVXID = true, HitPass(tag).VXID()
+		case vslparser.TagLink:
+			l := Link(tag)
+			if l.ChildType() != "bereq" {
+				continue
+			}
+			res.BeReqVXID = vslparser.VXID(l.ChildVXID())
+			if l.Reason() == "bgfetch" {
+				bgfetch = true
+			}
+		}
+	}
+
+	switch call {
+	case "HIT":
+		res.Outcome = OutcomeHit
+		if grace || bgfetch {
+			res.Outcome = OutcomeGraceHit
+		}
+		res.ObjectVXID = hitVXID
+	case "MISS":
+		res.Outcome = OutcomeMiss
+		if hitMiss {
+			res.Outcome = OutcomeHitMiss
+			res.ObjectVXID = hitVXID
+		}
+	case "PASS":
+		res.Outcome = OutcomePass
+		if hitPass {
+			res.Outcome = OutcomeHitPass
+			res.ObjectVXID = hitVXID
+		}
+	case "PIPE":
+		res.Outcome = OutcomePipe
+	case "SYNTH":
+		res.Outcome = OutcomeSynth
+	}
+	return res
+}
+
+// lastRestart returns the first client request in entries, or its last
+// restart if it was restarted and the restarts are available.
+func lastRestart(entries []vslparser.Entry) (vslparser.Entry, bool) {
+	chain := restartChain(entries)
+	if len(chain) == 0 {
+		return vslparser.Entry{}, false
+	}
+	return chain[len(chain)-1], true
+}
+
+// restartChain returns the first client request in entries followed by its
+// restarts which are available in entries, in the order they were started.
+func restartChain(entries []vslparser.Entry) []vslparser.Entry {
+	var (
+		req   vslparser.Entry
+		found bool
+	)
+	byVXID := make(map[vslparser.VXID]vslparser.Entry, len(entries))
+	for _, e := range entries {
+		byVXID[e.VXID] = e
+		if !found && e.Kind == vslparser.KindRequest {
+			req, found = e, true
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	chain := []vslparser.Entry{req}
+	for seen := map[vslparser.VXID]bool{req.VXID: true}; ; {
+		next, ok := restartOf(req, byVXID)
+		if !ok || seen[next.VXID] {
+			return chain
+		}
+		seen[next.VXID] = true
+		req = next
+		chain = append(chain, req)
+	}
+}
+
+// restartOf returns the request e was restarted into.
+func restartOf(e vslparser.Entry, byVXID map[vslparser.VXID]vslparser.Entry) (vslparser.Entry, bool) {
+	for _, tag := range e.Tags {
+		if tag.Key != vslparser.TagLink {
+			continue
+		}
+		l := Link(tag)
+		if l.ChildType() == "req" && l.Reason() == vslparser.ReasonRestart {
+			next, ok := byVXID[vslparser.VXID(l.ChildVXID())]
+			return next, ok
+		}
+	}
+	return vslparser.Entry{}, false
+}
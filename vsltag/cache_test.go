@@ -0,0 +1,135 @@
+package vsltag_test
+
+import (
+	"testing"
+
+	vsl "github.com/Showmax/vslparser"
+	"github.com/Showmax/vslparser/vsltag"
+)
+
+func TestHitMissHitPass(t *testing.T) {
+	hm := vsltag.HitMiss{Key: "HitMiss", Value: "32771 119.999779"}
+	if got := hm.VXID(); got != 32771 {
+		t.Errorf("HitMiss.VXID() = %d, want 32771", got)
+	}
+	if got, err := hm.TTL(); err != nil || got != 119.999779 {
+		t.Errorf("HitMiss.TTL() = %v, %v, want 119.999779", got, err)
+	}
+
+	hp := vsltag.HitPass{Key: "HitPass", Value: "32772"}
+	if got := hp.VXID(); got != 32772 {
+		t.Errorf("HitPass.VXID() = %d, want 32772", got)
+	}
+	if _, err := hp.TTL(); err == nil {
+		t.Errorf("HitPass.TTL() of a value without TTL should fail")
+	}
+}
+
+func requestWith(vxid vsl.VXID, tags ...vsl.Tag) vsl.Entry {
+	all := append([]vsl.Tag{{Key: "Begin", Value: "req 1 rxreq"}}, tags...)
+	all = append(all, vsl.Tag{Key: "End", Value: ""})
+	return vsl.Entry{Level: 1, Kind: vsl.KindRequest, VXID: vxid, Tags: all}
+}
+
+func TestCacheOutcome(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []vsl.Entry
+		want    vsltag.CacheResult
+	}{
+		{
+			name: "hit",
+			entries: []vsl.Entry{requestWith(2,
+				vsl.Tag{Key: "VCL_call", Value: "RECV"},
+				vsl.Tag{Key: "VCL_call", Value: "HASH"},
+				vsl.Tag{Key: "Hit", Value: "32770 115.393 10.000 0.000"},
+				vsl.Tag{Key: "VCL_call", Value: "HIT"},
+				vsl.Tag{Key: "VCL_call", Value: "DELIVER"},
+			)},
+			want: vsltag.CacheResult{Outcome: vsltag.OutcomeHit, ObjectVXID: 32770},
+		},
+		{
+			name: "hit logged by older Varnish",
+			entries: []vsl.Entry{requestWith(2,
+				vsl.Tag{Key: "Hit", Value: "32770"},
+				vsl.Tag{Key: "VCL_call", Value: "HIT"},
+			)},
+			want: vsltag.CacheResult{Outcome: vsltag.OutcomeHit, ObjectVXID: 32770},
+		},
+		{
+			name: "grace hit",
+			entries: []vsl.Entry{requestWith(2,
+				vsl.Tag{Key: "Hit", Value: "32770 -1.393 10.000 0.000"},
+				vsl.Tag{Key: "VCL_call", Value: "HIT"},
+				vsl.Tag{Key: "Link", Value: "bereq 3 bgfetch"},
+				vsl.Tag{Key: "VCL_call", Value: "DELIVER"},
+			)},
+			want: vsltag.CacheResult{Outcome: vsltag.OutcomeGraceHit, ObjectVXID: 32770, BeReqVXID: 3},
+		},
+		{
+			name: "miss",
+			entries: []vsl.Entry{
+				requestWith(2,
+					vsl.Tag{Key: "VCL_call", Value: "MISS"},
+					vsl.Tag{Key: "Link", Value: "bereq 3 fetch"},
+				),
+				{Level: 2, Kind: vsl.KindBeReq, VXID: 3},
+			},
+			want: vsltag.CacheResult{Outcome: vsltag.OutcomeMiss, BeReqVXID: 3},
+		},
+		{
+			name: "hitmiss",
+			entries: []vsl.Entry{requestWith(2,
+				vsl.Tag{Key: "HitMiss", Value: "32771 119.999779"},
+				vsl.Tag{Key: "VCL_call", Value: "MISS"},
+				vsl.Tag{Key: "Link", Value: "bereq 3 fetch"},
+			)},
+			want: vsltag.CacheResult{Outcome: vsltag.OutcomeHitMiss, ObjectVXID: 32771, BeReqVXID: 3},
+		},
+		{
+			name: "hitpass",
+			entries: []vsl.Entry{requestWith(2,
+				vsl.Tag{Key: "HitPass", Value: "32772 119.999779"},
+				vsl.Tag{Key: "VCL_call", Value: "PASS"},
+				vsl.Tag{Key: "Link", Value: "bereq 3 pass"},
+			)},
+			want: vsltag.CacheResult{Outcome: vsltag.OutcomeHitPass, ObjectVXID: 32772, BeReqVXID: 3},
+		},
+		{
+			name: "pipe",
+			entries: []vsl.Entry{requestWith(2,
+				vsl.Tag{Key: "VCL_call", Value: "PIPE"},
+				vsl.Tag{Key: "Link", Value: "bereq 3 pipe"},
+			)},
+			want: vsltag.CacheResult{Outcome: vsltag.OutcomePipe, BeReqVXID: 3},
+		},
+		{
+			name: "restart into synth",
+			entries: []vsl.Entry{
+				requestWith(2,
+					vsl.Tag{Key: "VCL_call", Value: "MISS"},
+					vsl.Tag{Key: "Link", Value: "bereq 3 fetch"},
+					vsl.Tag{Key: "Link", Value: "req 4 restart"},
+				),
+				{Level: 2, Kind: vsl.KindBeReq, VXID: 3},
+				requestWith(4,
+					vsl.Tag{Key: "VCL_call", Value: "RECV"},
+					vsl.Tag{Key: "VCL_call", Value: "SYNTH"},
+				),
+			},
+			want: vsltag.CacheResult{Outcome: vsltag.OutcomeSynth},
+		},
+		{
+			name:    "no request",
+			entries: []vsl.Entry{{Level: 1, Kind: vsl.KindBeReq, VXID: 3}},
+			want:    vsltag.CacheResult{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := vsltag.CacheOutcome(tt.entries); got != tt.want {
+				t.Errorf("CacheOutcome() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package vsltag
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Showmax/vslparser"
+)
+
+// FetchBody stands for Body fetched from backend. Logged when the backend
+// response body is fetched, e.g. "3 length stream".
+type FetchBody vslparser.Tag
+
+// Status returns the numeric body status.
+func (f FetchBody) Status() (int, error) { return intField(f.Value, 0) }
+
+// StatusName returns the name of the body status, e.g. "length", "chunked",
+// "eof" or "none".
+func (f FetchBody) StatusName() (string, error) { return field(f.Value, 1) }
+
+// Stream reports whether the body was streamed to the client while fetched.
+func (f FetchBody) Stream() bool {
+	s, err := field(f.Value, 2)
+	return err == nil && s == "stream"
+}
+
+// FetchError stands for Error while fetching object. Logged when a backend
+// fetch fails, e.g. "backend default: fail errno 111 (Connection refused)".
+type FetchError vslparser.Tag
+
+// Message returns the error message.
+func (f FetchError) Message() string { return f.Value }
+
+// Backend returns name of the backend for messages of the form
+// "backend NAME: ...", otherwise it's empty.
+func (f FetchError) Backend() string {
+	if !strings.HasPrefix(f.Value, "backend ") {
+		return ""
+	}
+	i := strings.Index(f.Value, ": ")
+	if i < 0 {
+		return ""
+	}
+	return f.Value[len("backend "):i]
+}
+
+// Gzip stands for G(un)zip performed on object. Logged for every gzip,
+// gunzip and gunzip test of a body, e.g. "G F E 7162 3122 80 24896 24906":
+//
+//	G F E 7162 3122 80 24896 24906
+//	| | | |    |    |  |     |
+//	| | | |    |    |  |     +- Bit length of compressed data
+//	| | | |    |    |  +------- Bit location of 'last' bit
+//	| | | |    |    +---------- Bit location of first deflate block
+//	| | | |    +--------------- Bytes output
+//	| | | +-------------------- Bytes input
+//	| | +---------------------- 'E': ESI, '-': Plain object
+//	| +------------------------ 'F': Fetch, 'D': Deliver
+//	+-------------------------- 'G': Gzip, 'U': Gunzip, 'u': Gunzip-test
+//
+// Failures are logged as "G(un)zip error: ..." messages.
+type Gzip vslparser.Tag
+
+// ErrorMessage returns the error message if the record reports a failure,
+// otherwise it's empty.
+func (g Gzip) ErrorMessage() string {
+	if strings.HasPrefix(g.Value, "G(un)zip error") {
+		return g.Value
+	}
+	return ""
+}
+
+// Op returns the operation, 'G' for gzip, 'U' for gunzip or 'u' for gunzip
+// test. It is 0 in case of failure records.
+func (g Gzip) Op() byte {
+	f, err := field(g.Value, 0)
+	if err != nil || len(f) != 1 || g.ErrorMessage() != "" {
+		return 0
+	}
+	return f[0]
+}
+
+// Fetch reports whether the operation was done during fetch rather than
+// during delivery.
+func (g Gzip) Fetch() bool { return g.flag(1) == "F" }
+
+// ESI reports whether the object was ESI processed.
+func (g Gzip) ESI() bool { return g.flag(2) == "E" }
+
+func (g Gzip) flag(i int) string {
+	f, _ := field(g.Value, i)
+	return f
+}
+
+func (g Gzip) BytesIn() (int64, error)  { return int64Field(g.Value, 3) }
+func (g Gzip) BytesOut() (int64, error) { return int64Field(g.Value, 4) }
+
+// Ratio returns the compression ratio, i.e. compressed size divided by
+// uncompressed size. It is 0 if the uncompressed size is 0.
+func (g Gzip) Ratio() (float64, error) {
+	in, err := g.BytesIn()
+	if err != nil {
+		return 0, err
+	}
+	out, err := g.BytesOut()
+	if err != nil {
+		return 0, err
+	}
+
+	compressed, uncompressed := out, in
+	switch g.Op() {
+	case 'G':
+	case 'U', 'u':
+		compressed, uncompressed = in, out
+	default:
+		return 0, fmt.Errorf("unknown gzip operation in %q", g.Value)
+	}
+	if uncompressed == 0 {
+		return 0, nil
+	}
+	return float64(compressed) / float64(uncompressed), nil
+}
+
+// Filters stands for Body filters. It lists the fetch filters (VFPs) applied
+// to the backend response body, e.g. "esi_gzip gunzip".
+type Filters vslparser.Tag
+
+func (f Filters) Names() []string { return strings.Fields(f.Value) }
+
+// VfpAcct stands for Fetch filter accounting. Logged for every fetch filter
+// with the number of calls and bytes it produced, e.g. "gunzip 2 7162".
+type VfpAcct vslparser.Tag
+
+func (v VfpAcct) Filter() string {
+	sp := strings.SplitN(v.Value, " ", 2)
+	return sp[0]
+}
+
+func (v VfpAcct) Calls() (int64, error) { return int64Field(v.Value, 1) }
+func (v VfpAcct) Bytes() (int64, error) { return int64Field(v.Value, 2) }
+
+// Length stands for Size of object body. The length of the fetched body in
+// bytes.
+type Length vslparser.Tag
+
+func (l Length) Bytes() (int64, error) { return int64Field(l.Value, 0) }
@@ -0,0 +1,99 @@
+package vsltag_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Showmax/vslparser/vsltag"
+)
+
+func TestFetchBody(t *testing.T) {
+	f := vsltag.FetchBody{Key: "Fetch_Body", Value: "3 length stream"}
+	if got, err := f.Status(); err != nil || got != 3 {
+		t.Errorf("Status() = %d, %v, want 3", got, err)
+	}
+	if got, err := f.StatusName(); err != nil || got != "length" {
+		t.Errorf("StatusName() = %q, %v, want length", got, err)
+	}
+	if !f.Stream() {
+		t.Errorf("Stream() = false, want true")
+	}
+	if (vsltag.FetchBody{Key: "Fetch_Body", Value: "2 chunked -"}).Stream() {
+		t.Errorf("Stream() = true, want false")
+	}
+}
+
+func TestFetchError(t *testing.T) {
+	tests := []struct {
+		value, backend string
+	}{
+		{"backend default: fail errno 111 (Connection refused)", "default"},
+		{"no backend connection", ""},
+	}
+	for _, tt := range tests {
+		if got := (vsltag.FetchError{Key: "FetchError", Value: tt.value}).Backend(); got != tt.backend {
+			t.Errorf("%q: Backend() = %q, want %q", tt.value, got, tt.backend)
+		}
+	}
+}
+
+func TestGzip(t *testing.T) {
+	tests := []struct {
+		value      string
+		op         byte
+		fetch, esi bool
+		ratio      float64
+	}{
+		{"G F E 7162 3122 80 24896 24906", 'G', true, true, 3122.0 / 7162},
+		{"U D - 3122 7162 80 24896 24906", 'U', false, false, 3122.0 / 7162},
+		{"u F - 0 0 80 80 80", 'u', true, false, 0},
+	}
+	for _, tt := range tests {
+		g := vsltag.Gzip{Key: "Gzip", Value: tt.value}
+		if got := g.Op(); got != tt.op {
+			t.Errorf("%q: Op() = %c, want %c", tt.value, got, tt.op)
+		}
+		if g.Fetch() != tt.fetch || g.ESI() != tt.esi {
+			t.Errorf("%q: Fetch(), ESI() = %v, %v, want %v, %v", tt.value, g.Fetch(), g.ESI(), tt.fetch, tt.esi)
+		}
+		if got, err := g.Ratio(); err != nil || got != tt.ratio {
+			t.Errorf("%q: Ratio() = %v, %v, want %v", tt.value, got, err, tt.ratio)
+		}
+		if g.ErrorMessage() != "" {
+			t.Errorf("%q: ErrorMessage() = %q, want empty", tt.value, g.ErrorMessage())
+		}
+	}
+
+	g := vsltag.Gzip{Key: "Gzip", Value: "G(un)zip error: -3 (invalid stored block lengths)"}
+	if g.ErrorMessage() == "" || g.Op() != 0 {
+		t.Errorf("failure record not recognized")
+	}
+	if _, err := g.Ratio(); err == nil {
+		t.Errorf("Ratio() of failure record should fail")
+	}
+}
+
+func TestFiltersVfpAcctLength(t *testing.T) {
+	f := vsltag.Filters{Key: "Filters", Value: " esi_gzip gunzip"}
+	if got := f.Names(); !reflect.DeepEqual(got, []string{"esi_gzip", "gunzip"}) {
+		t.Errorf("Names() = %v", got)
+	}
+
+	v := vsltag.VfpAcct{Key: "VfpAcct", Value: "gunzip 2 7162"}
+	if v.Filter() != "gunzip" {
+		t.Errorf("Filter() = %q, want gunzip", v.Filter())
+	}
+	if got, err := v.Calls(); err != nil || got != 2 {
+		t.Errorf("Calls() = %d, %v, want 2", got, err)
+	}
+	if got, err := v.Bytes(); err != nil || got != 7162 {
+		t.Errorf("Bytes() = %d, %v, want 7162", got, err)
+	}
+
+	if got, err := (vsltag.Length{Key: "Length", Value: "12"}).Bytes(); err != nil || got != 12 {
+		t.Errorf("Length.Bytes() = %d, %v, want 12", got, err)
+	}
+	if _, err := (vsltag.Length{Key: "Length", Value: "x"}).Bytes(); err == nil {
+		t.Errorf("Length.Bytes() of malformed value should fail")
+	}
+}
@@ -0,0 +1,127 @@
+package vsltag
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Showmax/vslparser"
+)
+
+// H2FrameHeader is a decoded HTTP/2 frame header (RFC 7540, section 4.1).
+type H2FrameHeader struct {
+	// Length is the length of the frame payload.
+	Length uint32
+	Type   uint8
+	Flags  uint8
+	// StreamID is the stream identifier with the reserved bit cleared.
+	StreamID uint32
+}
+
+// h2FrameTypes are names of HTTP/2 frame types indexed by type.
+var h2FrameTypes = []string{
+	"DATA", "HEADERS", "PRIORITY", "RST_STREAM", "SETTINGS", "PUSH_PROMISE",
+	"PING", "GOAWAY", "WINDOW_UPDATE", "CONTINUATION",
+}
+
+// TypeName returns the name of the frame type, e.g. "HEADERS".
+func (h H2FrameHeader) TypeName() string {
+	if int(h.Type) < len(h2FrameTypes) {
+		return h2FrameTypes[h.Type]
+	}
+	return "UNKNOWN(" + strconv.Itoa(int(h.Type)) + ")"
+}
+
+// parseH2FrameHeader decodes the hex dump of a frame header.
+func parseH2FrameHeader(s string) (H2FrameHeader, error) {
+	b, _, err := parseHexDump(s)
+	if err != nil {
+		return H2FrameHeader{}, err
+	}
+	if len(b) != 9 {
+		return H2FrameHeader{}, fmt.Errorf("frame header has %d bytes, want 9", len(b))
+	}
+	return H2FrameHeader{
+		Length:   uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2]),
+		Type:     b[3],
+		Flags:    b[4],
+		StreamID: binary.BigEndian.Uint32(b[5:]) & 0x7fffffff,
+	}, nil
+}
+
+// parseHexDump decodes a binary dump logged by Varnish. The dump may be
+// enclosed in brackets or prefixed with its length in brackets, e.g.
+// "[9] 000006040000000000". The length is the size of the dumped data, which
+// is more than len(b) if the dump was truncated.
+func parseHexDump(s string) (b []byte, length int, err error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "[") {
+		i := strings.IndexByte(s, ']')
+		if i < 0 {
+			return nil, 0, fmt.Errorf("unterminated bracket in %q", s)
+		}
+		inner, rest := s[1:i], strings.TrimSpace(s[i+1:])
+		if rest == "" {
+			s = inner
+		} else {
+			if length, err = strconv.Atoi(inner); err != nil {
+				return nil, 0, fmt.Errorf("invalid length of %q: %w", s, err)
+			}
+			s = rest
+		}
+	}
+	s = strings.ReplaceAll(s, " ", "")
+
+	b, err = hex.DecodeString(s)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid hex dump: %w", err)
+	}
+	if length < len(b) {
+		length = len(b)
+	}
+	return b, length, nil
+}
+
+// H2RxHdr stands for Received HTTP2 frame header.
+type H2RxHdr vslparser.Tag
+
+func (h H2RxHdr) Frame() (H2FrameHeader, error) { return parseH2FrameHeader(h.Value) }
+
+// H2TxHdr stands for Transmitted HTTP2 frame header.
+type H2TxHdr vslparser.Tag
+
+func (h H2TxHdr) Frame() (H2FrameHeader, error) { return parseH2FrameHeader(h.Value) }
+
+// H2RxBody stands for Received HTTP2 frame body. The dump may be truncated,
+// see Length.
+type H2RxBody vslparser.Tag
+
+func (h H2RxBody) Bytes() ([]byte, error) {
+	b, _, err := parseHexDump(h.Value)
+	return b, err
+}
+
+// Length returns the length of the frame body, which may be more than the
+// number of dumped bytes.
+func (h H2RxBody) Length() (int, error) {
+	_, length, err := parseHexDump(h.Value)
+	return length, err
+}
+
+// H2TxBody stands for Transmitted HTTP2 frame body. The dump may be
+// truncated, see Length.
+type H2TxBody vslparser.Tag
+
+func (h H2TxBody) Bytes() ([]byte, error) {
+	b, _, err := parseHexDump(h.Value)
+	return b, err
+}
+
+// Length returns the length of the frame body, which may be more than the
+// number of dumped bytes.
+func (h H2TxBody) Length() (int, error) {
+	_, length, err := parseHexDump(h.Value)
+	return length, err
+}
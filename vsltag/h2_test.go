@@ -0,0 +1,52 @@
+package vsltag_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Showmax/vslparser/vsltag"
+)
+
+func TestH2Hdr(t *testing.T) {
+	tests := []struct {
+		value string
+		want  vsltag.H2FrameHeader
+		name  string
+	}{
+		{"[000006040000000000]", vsltag.H2FrameHeader{Length: 6, Type: 4}, "SETTINGS"},
+		{"[9] 00001c01050000000b", vsltag.H2FrameHeader{Length: 28, Type: 1, Flags: 5, StreamID: 11}, "HEADERS"},
+		{"0000000f0080000001", vsltag.H2FrameHeader{Type: 15, StreamID: 1}, "UNKNOWN(15)"},
+	}
+	for _, tt := range tests {
+		got, err := vsltag.H2RxHdr{Key: "H2RxHdr", Value: tt.value}.Frame()
+		if err != nil || got != tt.want {
+			t.Errorf("%q: Frame() = %+v, %v, want %+v", tt.value, got, err, tt.want)
+		}
+		if got.TypeName() != tt.name {
+			t.Errorf("%q: TypeName() = %q, want %q", tt.value, got.TypeName(), tt.name)
+		}
+	}
+
+	for _, value := range []string{"[0000060400]", "[zz0006040000000000]", "[000006040000000000"} {
+		if _, err := (vsltag.H2TxHdr{Key: "H2TxHdr", Value: value}).Frame(); err == nil {
+			t.Errorf("%q: Frame() of malformed value should fail", value)
+		}
+	}
+}
+
+func TestH2Body(t *testing.T) {
+	b := vsltag.H2TxBody{Key: "H2TxBody", Value: "[12] 00030000006400040000ffff"}
+	got, err := b.Bytes()
+	if err != nil || !bytes.Equal(got, []byte{0, 3, 0, 0, 0, 0x64, 0, 4, 0, 0, 0xff, 0xff}) {
+		t.Errorf("Bytes() = %x, %v", got, err)
+	}
+
+	// Truncated dump.
+	rx := vsltag.H2RxBody{Key: "H2RxBody", Value: "[4096] 3c68746d6c3e"}
+	if got, err := rx.Bytes(); err != nil || string(got) != "<html>" {
+		t.Errorf("Bytes() = %q, %v, want <html>", got, err)
+	}
+	if got, err := rx.Length(); err != nil || got != 4096 {
+		t.Errorf("Length() = %d, %v, want 4096", got, err)
+	}
+}
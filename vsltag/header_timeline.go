@@ -0,0 +1,229 @@
+package vsltag
+
+import (
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/Showmax/vslparser"
+)
+
+// HeaderFamily identifies a set of HTTP headers tracked by its own pair of set
+// and unset tags.
+type HeaderFamily int
+
+const (
+	// ReqHeaders are client request headers (ReqHeader, ReqUnset).
+	ReqHeaders HeaderFamily = iota
+	// RespHeaders are client response headers (RespHeader, RespUnset).
+	RespHeaders
+	// BereqHeaders are backend request headers (BereqHeader, BereqUnset).
+	BereqHeaders
+	// BerespHeaders are backend response headers (BerespHeader,
+	// BerespUnset).
+	BerespHeaders
+)
+
+func (f HeaderFamily) String() string {
+	switch f {
+	case ReqHeaders:
+		return "req"
+	case RespHeaders:
+		return "resp"
+	case BereqHeaders:
+		return "bereq"
+	case BerespHeaders:
+		return "beresp"
+	}
+	return "unknown"
+}
+
+// keys returns keys of set and unset tags of family f.
+func (f HeaderFamily) keys() (set, unset string) {
+	switch f {
+	case ReqHeaders:
+		return vslparser.TagReqHeader, vslparser.TagReqUnset
+	case RespHeaders:
+		return vslparser.TagRespHeader, vslparser.TagRespUnset
+	case BereqHeaders:
+		return vslparser.TagBeReqHeader, vslparser.TagBeReqUnset
+	case BerespHeaders:
+		return vslparser.TagBeRespHeader, vslparser.TagBeRespUnset
+	}
+	return "", ""
+}
+
+// headerFamilyOf returns header family of a tag key and whether the tag sets
+// or unsets a header.
+func headerFamilyOf(key string) (f HeaderFamily, op HeaderOp, ok bool) {
+	switch key {
+	case vslparser.TagReqHeader:
+		return ReqHeaders, HeaderSet, true
+	case vslparser.TagReqUnset:
+		return ReqHeaders, HeaderUnset, true
+	case vslparser.TagRespHeader:
+		return RespHeaders, HeaderSet, true
+	case vslparser.TagRespUnset:
+		return RespHeaders, HeaderUnset, true
+	case vslparser.TagBeReqHeader:
+		return BereqHeaders, HeaderSet, true
+	case vslparser.TagBeReqUnset:
+		return BereqHeaders, HeaderUnset, true
+	case vslparser.TagBeRespHeader:
+		return BerespHeaders, HeaderSet, true
+	case vslparser.TagBeRespUnset:
+		return BerespHeaders, HeaderUnset, true
+	}
+	return 0, 0, false
+}
+
+// HeaderOp is an operation performed on a header.
+type HeaderOp int
+
+const (
+	// HeaderSet means that a header was added.
+	HeaderSet HeaderOp = iota
+	// HeaderUnset means that a header was removed.
+	HeaderUnset
+)
+
+// HeaderEvent is a single set or unset operation on a header.
+type HeaderEvent struct {
+	Family HeaderFamily
+	Op     HeaderOp
+	// Name is the canonicalized header name.
+	Name  string
+	Value string
+	// Stage is the VCL subroutine (as logged by VCL_call, e.g. "RECV")
+	// which performed the operation. It is empty for operations performed
+	// by Varnish itself outside of VCL, e.g. headers received from the
+	// client.
+	Stage string
+	// Index is the index of the tag in Entry.Tags.
+	Index int
+}
+
+// HeaderTimeline holds the history of set and unset operations of all headers
+// of an Entry interleaved with VCL subroutine invocations. It allows to tell
+// what a header looked like at each VCL stage.
+type HeaderTimeline struct {
+	events []HeaderEvent
+	stages []vclStage
+}
+
+// NewHeaderTimeline replays header tags of e along with VCL_call and
+// VCL_return tags.
+func NewHeaderTimeline(e vslparser.Entry) *HeaderTimeline {
+	t := &HeaderTimeline{stages: vclStages(e.Tags)}
+
+	stage := 0 // index of the last stage which may contain the tag
+	for i, tag := range e.Tags {
+		f, op, ok := headerFamilyOf(tag.Key)
+		if !ok {
+			continue
+		}
+		h, ok := ParseHeader(tag)
+		if !ok {
+			continue
+		}
+		ev := HeaderEvent{
+			Family: f,
+			Op:     op,
+			Name:   h.Name(),
+			Value:  h.Value(),
+			Index:  i,
+		}
+		for stage < len(t.stages) && t.stages[stage].end < i {
+			stage++
+		}
+		if stage < len(t.stages) && t.stages[stage].start < i && i < t.stages[stage].end {
+			ev.Stage = t.stages[stage].name
+		}
+		t.events = append(t.events, ev)
+	}
+
+	return t
+}
+
+// Events returns all header operations in the order they were logged.
+func (t *HeaderTimeline) Events() []HeaderEvent { return t.events }
+
+// History returns operations performed on header name of family f in the order
+// they were logged. Header name is matched case-insensitively.
+func (t *HeaderTimeline) History(f HeaderFamily, name string) []HeaderEvent {
+	name = http.CanonicalHeaderKey(name)
+
+	var history []HeaderEvent
+	for _, ev := range t.events {
+		if ev.Family == f && ev.Name == name {
+			history = append(history, ev)
+		}
+	}
+	return history
+}
+
+// Original returns values of header name of family f before the first VCL
+// subroutine which could modify them was called, i.e. as received from the
+// client or the backend, or as initially set by Varnish. That is the first
+// subroutine called after the first header of the family was logged, e.g.
+// RECV for request headers, DELIVER or SYNTH for response headers and
+// BACKEND_RESPONSE or BACKEND_ERROR for backend response headers.
+func (t *HeaderTimeline) Original(f HeaderFamily, name string) []string {
+	first := -1
+	for _, ev := range t.events {
+		if ev.Family == f {
+			first = ev.Index
+			break
+		}
+	}
+	if first < 0 {
+		return nil
+	}
+
+	end := math.MaxInt
+	for _, s := range t.stages {
+		if s.start > first {
+			end = s.start
+			break
+		}
+	}
+	return t.valuesBefore(f, name, end)
+}
+
+// At returns values of header name of family f as they were when the VCL
+// subroutine stage (e.g. "RECV", "DELIVER") returned, so the changes done by
+// the subroutine itself are included. The subroutine name is matched
+// case-insensitively. If the subroutine was called several times, its first
+// invocation is used. The ok result is false if the subroutine was never
+// called.
+func (t *HeaderTimeline) At(f HeaderFamily, name, stage string) (values []string, ok bool) {
+	for _, s := range t.stages {
+		if strings.EqualFold(s.name, stage) {
+			return t.valuesBefore(f, name, s.end), true
+		}
+	}
+	return nil, false
+}
+
+// Final returns values of header name of family f after all operations.
+func (t *HeaderTimeline) Final(f HeaderFamily, name string) []string {
+	return t.valuesBefore(f, name, math.MaxInt)
+}
+
+// valuesBefore replays operations on header name of family f logged before
+// tag index end.
+func (t *HeaderTimeline) valuesBefore(f HeaderFamily, name string, end int) []string {
+	h := make(http.Header)
+	for _, ev := range t.History(f, name) {
+		if ev.Index >= end {
+			break
+		}
+		switch ev.Op {
+		case HeaderSet:
+			h.Add(ev.Name, ev.Value)
+		case HeaderUnset:
+			removeHeaderValue(h, ev.Name, ev.Value)
+		}
+	}
+	return h.Values(name)
+}
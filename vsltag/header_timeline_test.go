@@ -0,0 +1,99 @@
+package vsltag_test
+
+import (
+	"reflect"
+	"testing"
+
+	vsl "github.com/Showmax/vslparser"
+	"github.com/Showmax/vslparser/vsltag"
+)
+
+func TestHeaderTimeline(t *testing.T) {
+	tl := vsltag.NewHeaderTimeline(httpRequestEntry)
+
+	if got := tl.Original(vsltag.ReqHeaders, "magic"); !reflect.DeepEqual(got, []string{"aloha"}) {
+		t.Errorf("Original(magic) = %v, want [aloha]", got)
+	}
+	if got := tl.Original(vsltag.ReqHeaders, "cookie"); !reflect.DeepEqual(got, []string{"a=1"}) {
+		t.Errorf("Original(cookie) = %v, want [a=1]", got)
+	}
+
+	got, ok := tl.At(vsltag.ReqHeaders, "Magic", "recv")
+	if !ok || !reflect.DeepEqual(got, []string{"mahalo"}) {
+		t.Errorf("At(Magic, recv) = %v, %v, want [mahalo], true", got, ok)
+	}
+	got, ok = tl.At(vsltag.ReqHeaders, "Cookie", "RECV")
+	if !ok || got != nil {
+		t.Errorf("At(Cookie, RECV) = %v, %v, want [], true", got, ok)
+	}
+	if _, ok = tl.At(vsltag.ReqHeaders, "Cookie", "HASH"); ok {
+		t.Errorf("At(Cookie, HASH) reports stage which was never called")
+	}
+
+	got, _ = tl.At(vsltag.RespHeaders, "X-Varnish", "DELIVER")
+	if got != nil {
+		t.Errorf("At(X-Varnish, DELIVER) = %v, want []", got)
+	}
+	if got := tl.Final(vsltag.RespHeaders, "Content-Length"); !reflect.DeepEqual(got, []string{"282"}) {
+		t.Errorf("Final(Content-Length) = %v, want [282]", got)
+	}
+
+	history := tl.History(vsltag.ReqHeaders, "MAGIC")
+	want := []vsltag.HeaderEvent{
+		{Family: vsltag.ReqHeaders, Op: vsltag.HeaderSet, Name: "Magic", Value: "aloha", Index: 6},
+		{Family: vsltag.ReqHeaders, Op: vsltag.HeaderUnset, Name: "Magic", Value: "aloha", Stage: "RECV", Index: 11},
+		{Family: vsltag.ReqHeaders, Op: vsltag.HeaderSet, Name: "Magic", Value: "mahalo", Stage: "RECV", Index: 12},
+	}
+	if !reflect.DeepEqual(history, want) {
+		t.Errorf("History(MAGIC) = %+v, want %+v", history, want)
+	}
+}
+
+func TestHeaderTimeline_OriginalResp(t *testing.T) {
+	tl := vsltag.NewHeaderTimeline(httpRequestEntry)
+
+	// Response headers are logged after RECV, so they are original until
+	// DELIVER.
+	if got := tl.Original(vsltag.RespHeaders, "X-Varnish"); !reflect.DeepEqual(got, []string{"32770"}) {
+		t.Errorf("Original(X-Varnish) = %v, want [32770]", got)
+	}
+	if got := tl.Original(vsltag.RespHeaders, "Content-Length"); got != nil {
+		t.Errorf("Original(Content-Length) = %v, want []", got)
+	}
+	if got := tl.Original(vsltag.BereqHeaders, "Host"); got != nil {
+		t.Errorf("Original(Host) of missing family = %v, want []", got)
+	}
+}
+
+func TestHeaderTimeline_OriginalBeresp(t *testing.T) {
+	e := vsl.Entry{
+		Level: 2,
+		Kind:  vsl.KindBeReq,
+		VXID:  3,
+		Tags: []vsl.Tag{
+			{Key: "Begin", Value: "bereq 2 fetch"},
+			{Key: "BereqHeader", Value: "Host: localhost:6081"},
+			{Key: "VCL_call", Value: "BACKEND_FETCH"},
+			{Key: "BereqHeader", Value: "X-Fetch: 1"},
+			{Key: "VCL_return", Value: "fetch"},
+			{Key: "BerespStatus", Value: "200"},
+			{Key: "BerespHeader", Value: "Server: origin"},
+			{Key: "VCL_call", Value: "BACKEND_RESPONSE"},
+			{Key: "BerespUnset", Value: "Server: origin"},
+			{Key: "BerespHeader", Value: "Server: varnish"},
+			{Key: "VCL_return", Value: "deliver"},
+			{Key: "End", Value: ""},
+		},
+	}
+	tl := vsltag.NewHeaderTimeline(e)
+
+	if got := tl.Original(vsltag.BerespHeaders, "Server"); !reflect.DeepEqual(got, []string{"origin"}) {
+		t.Errorf("Original(Server) = %v, want [origin]", got)
+	}
+	if got := tl.Final(vsltag.BerespHeaders, "Server"); !reflect.DeepEqual(got, []string{"varnish"}) {
+		t.Errorf("Final(Server) = %v, want [varnish]", got)
+	}
+	if got := tl.Original(vsltag.BereqHeaders, "X-Fetch"); got != nil {
+		t.Errorf("Original(X-Fetch) = %v, want []", got)
+	}
+}
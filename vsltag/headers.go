@@ -0,0 +1,99 @@
+package vsltag
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Showmax/vslparser"
+)
+
+// Header is a header line logged by ReqHeader, RespHeader, BereqHeader,
+// BerespHeader or one of the corresponding unset tags, e.g.
+// "Host: localhost:6081".
+type Header struct {
+	name, value string
+}
+
+// ParseHeader splits the value of a header tag into header name and value.
+// The ok result is false if the value is not a header line.
+func ParseHeader(tag vslparser.Tag) (h Header, ok bool) {
+	name, value, ok := splitHeader(tag.Value)
+	if !ok {
+		return Header{}, false
+	}
+	return Header{name: http.CanonicalHeaderKey(name), value: value}, true
+}
+
+// Name returns the canonicalized header name, e.g. "Content-Type" for
+// "content-type: text/html".
+func (h Header) Name() string { return h.name }
+
+// Value returns the header value with leading whitespace removed.
+func (h Header) Value() string { return h.value }
+
+// Matches reports whether the header name is name, matched
+// case-insensitively.
+func (h Header) Matches(name string) bool { return strings.EqualFold(h.name, name) }
+
+// Headers returns headers of family f of e after all set and unset operations,
+// i.e. the final state of the headers.
+func Headers(e vslparser.Entry, f HeaderFamily) http.Header {
+	setKey, unsetKey := f.keys()
+	return replayHeaders(e.Tags, setKey, unsetKey)
+}
+
+// HeaderValues returns values of header name of family f of e in the order
+// they were set. Values removed by a later unset tag are left out. Header name
+// is matched case-insensitively.
+func HeaderValues(e vslparser.Entry, f HeaderFamily, name string) []string {
+	return Headers(e, f).Values(name)
+}
+
+// replayHeaders applies header set (setKey) and unset (unsetKey) tags in the
+// order they were logged and returns the resulting headers.
+func replayHeaders(tags []vslparser.Tag, setKey, unsetKey string) http.Header {
+	h := make(http.Header)
+	for _, tag := range tags {
+		switch tag.Key {
+		case setKey:
+			if name, value, ok := splitHeader(tag.Value); ok {
+				h.Add(name, value)
+			}
+		case unsetKey:
+			if name, value, ok := splitHeader(tag.Value); ok {
+				removeHeaderValue(h, name, value)
+			}
+		}
+	}
+	return h
+}
+
+// splitHeader splits a header tag value, e.g. "Host: localhost:6081", into
+// header name and value.
+func splitHeader(s string) (name, value string, ok bool) {
+	i := strings.IndexByte(s, ':')
+	if i <= 0 {
+		return "", "", false
+	}
+	return s[:i], strings.TrimLeft(s[i+1:], " \t"), true
+}
+
+// removeHeaderValue removes the first occurrence of header name with value
+// from h. Varnish logs the complete header line being unset, so this removes
+// exactly the header instance which was unset in VCL.
+func removeHeaderValue(h http.Header, name, value string) {
+	key := http.CanonicalHeaderKey(name)
+	values := h[key]
+	for i, v := range values {
+		if v != value {
+			continue
+		}
+		values = append(values[:i:i], values[i+1:]...)
+		if len(values) == 0 {
+			delete(h, key)
+		} else {
+			h[key] = values
+		}
+		return
+	}
+}
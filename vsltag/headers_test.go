@@ -0,0 +1,48 @@
+package vsltag_test
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	vsl "github.com/Showmax/vslparser"
+	"github.com/Showmax/vslparser/vsltag"
+)
+
+func TestParseHeader(t *testing.T) {
+	h, ok := vsltag.ParseHeader(vsl.Tag{Key: "RespHeader", Value: "content-type:  text/html; charset=utf-8"})
+	if !ok {
+		t.Fatalf("ParseHeader() failed")
+	}
+	if h.Name() != "Content-Type" || h.Value() != "text/html; charset=utf-8" {
+		t.Errorf("ParseHeader() = %q: %q", h.Name(), h.Value())
+	}
+	if !h.Matches("CONTENT-TYPE") || h.Matches("Content") {
+		t.Errorf("Matches() is wrong")
+	}
+
+	if _, ok := vsltag.ParseHeader(vsl.Tag{Key: "ReqHeader", Value: ": foo"}); ok {
+		t.Errorf("ParseHeader() of a value without name should fail")
+	}
+}
+
+func TestHeaderValues(t *testing.T) {
+	e := vsl.Entry{Tags: []vsl.Tag{
+		{Key: "ReqHeader", Value: "Accept: text/html"},
+		{Key: "ReqHeader", Value: "accept: */*"},
+		{Key: "ReqHeader", Value: "ACCEPT: image/png"},
+		{Key: "ReqUnset", Value: "accept: */*"},
+		{Key: "RespHeader", Value: "Accept: nonsense"},
+	}}
+
+	if got := vsltag.HeaderValues(e, vsltag.ReqHeaders, "accept"); !reflect.DeepEqual(got, []string{"text/html", "image/png"}) {
+		t.Errorf("HeaderValues(accept) = %v", got)
+	}
+	if got := vsltag.HeaderValues(e, vsltag.BereqHeaders, "Accept"); got != nil {
+		t.Errorf("HeaderValues(Accept) of BereqHeaders = %v, want none", got)
+	}
+	want := http.Header{"Accept": {"nonsense"}}
+	if got := vsltag.Headers(e, vsltag.RespHeaders); !reflect.DeepEqual(got, want) {
+		t.Errorf("Headers(RespHeaders) = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,184 @@
+package vsltag
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Showmax/vslparser"
+)
+
+// httpTags holds keys of tags describing one side of an HTTP exchange.
+type httpTags struct {
+	method, url, reqProtocol  string
+	reqHeader, reqUnset       string
+	status, reason, respProto string
+	respHeader, respUnset     string
+}
+
+var (
+	clientHTTPTags = httpTags{
+		method:      vslparser.TagReqMethod,
+		url:         vslparser.TagReqURL,
+		reqProtocol: vslparser.TagReqProtocol,
+		reqHeader:   vslparser.TagReqHeader,
+		reqUnset:    vslparser.TagReqUnset,
+		status:      vslparser.TagRespStatus,
+		reason:      vslparser.TagRespReason,
+		respProto:   vslparser.TagRespProtocol,
+		respHeader:  vslparser.TagRespHeader,
+		respUnset:   vslparser.TagRespUnset,
+	}
+	backendHTTPTags = httpTags{
+		method:      vslparser.TagBeReqMethod,
+		url:         vslparser.TagBeReqURL,
+		reqProtocol: vslparser.TagBeReqProtocol,
+		reqHeader:   vslparser.TagBeReqHeader,
+		reqUnset:    vslparser.TagBeReqUnset,
+		status:      vslparser.TagBerespStatus,
+		reason:      vslparser.TagBeRespReason,
+		respProto:   vslparser.TagBeRespProtocol,
+		respHeader:  vslparser.TagBeRespHeader,
+		respUnset:   vslparser.TagBeRespUnset,
+	}
+)
+
+func httpTagsOf(e vslparser.Entry) (httpTags, error) {
+	switch e.Kind {
+	case vslparser.KindRequest:
+		return clientHTTPTags, nil
+	case vslparser.KindBeReq:
+		return backendHTTPTags, nil
+	}
+	return httpTags{}, fmt.Errorf("entry %d of kind %q holds no HTTP exchange", e.VXID, e.Kind)
+}
+
+// HTTPRequest reconstructs the HTTP request of a Request or BeReq entry. The
+// request reflects the final state after all VCL modifications, i.e. the last
+// logged method, URL and protocol, and headers after applying all set and
+// unset operations in order.
+//
+// Just like for requests received by net/http server, the Host header is moved
+// to the Host field. RemoteAddr is taken from ReqStart for client requests and
+// from the local address of BackendOpen for backend requests. The body is
+// always empty.
+func HTTPRequest(e vslparser.Entry) (*http.Request, error) {
+	keys, err := httpTagsOf(e)
+	if err != nil {
+		return nil, err
+	}
+	tags := vslparser.Tags(e.Tags)
+
+	method, ok := tags.LastWithKey(keys.method)
+	if !ok {
+		return nil, fmt.Errorf("no %s tag", keys.method)
+	}
+	rawURL, ok := tags.LastWithKey(keys.url)
+	if !ok {
+		return nil, fmt.Errorf("no %s tag", keys.url)
+	}
+	u, err := url.ParseRequestURI(rawURL.Value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", keys.url, err)
+	}
+
+	req := &http.Request{
+		Method:     method.Value,
+		URL:        u,
+		RequestURI: rawURL.Value,
+		Header:     replayHeaders(e.Tags, keys.reqHeader, keys.reqUnset),
+		Body:       http.NoBody,
+	}
+	if err := setProto(tags, keys.reqProtocol, &req.Proto, &req.ProtoMajor, &req.ProtoMinor); err != nil {
+		return nil, err
+	}
+
+	req.Host = req.Header.Get("Host")
+	req.Header.Del("Host")
+	req.ContentLength = contentLength(req.Header, 0)
+
+	switch e.Kind {
+	case vslparser.KindRequest:
+		if tag, ok := tags.FirstWithKey(vslparser.TagReqStart); ok {
+			if sp := strings.Fields(tag.Value); len(sp) >= 2 {
+				req.RemoteAddr = net.JoinHostPort(sp[0], sp[1])
+			}
+		}
+	case vslparser.KindBeReq:
+		if tag, ok := tags.LastWithKey(vslparser.TagBackendOpen); ok {
+			if sp := strings.Fields(tag.Value); len(sp) >= 6 {
+				req.RemoteAddr = net.JoinHostPort(sp[4], sp[5])
+			}
+		}
+	}
+
+	return req, nil
+}
+
+// HTTPResponse reconstructs the HTTP response of a Request or BeReq entry. The
+// response reflects the final state after all VCL modifications, just like in
+// case of HTTPRequest, which is used to fill the Request field if possible.
+//
+// ContentLength is taken from the Content-Length header, -1 means unknown.
+// The body is always empty.
+func HTTPResponse(e vslparser.Entry) (*http.Response, error) {
+	keys, err := httpTagsOf(e)
+	if err != nil {
+		return nil, err
+	}
+	tags := vslparser.Tags(e.Tags)
+
+	status, ok := tags.LastWithKey(keys.status)
+	if !ok {
+		return nil, fmt.Errorf("no %s tag", keys.status)
+	}
+	code, err := strconv.Atoi(status.Value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", keys.status, err)
+	}
+
+	resp := &http.Response{
+		StatusCode: code,
+		Status:     status.Value,
+		Header:     replayHeaders(e.Tags, keys.respHeader, keys.respUnset),
+		Body:       http.NoBody,
+	}
+	if reason, ok := tags.LastWithKey(keys.reason); ok {
+		resp.Status += " " + reason.Value
+	}
+	if err := setProto(tags, keys.respProto, &resp.Proto, &resp.ProtoMajor, &resp.ProtoMinor); err != nil {
+		return nil, err
+	}
+	resp.ContentLength = contentLength(resp.Header, -1)
+
+	if req, err := HTTPRequest(e); err == nil {
+		resp.Request = req
+	}
+
+	return resp, nil
+}
+
+func setProto(tags vslparser.Tags, key string, proto *string, major, minor *int) error {
+	tag, ok := tags.LastWithKey(key)
+	if !ok {
+		return fmt.Errorf("no %s tag", key)
+	}
+	var valid bool
+	*major, *minor, valid = http.ParseHTTPVersion(tag.Value)
+	if !valid {
+		return fmt.Errorf("invalid %s %q", key, tag.Value)
+	}
+	*proto = tag.Value
+	return nil
+}
+
+func contentLength(h http.Header, def int64) int64 {
+	n, err := strconv.ParseInt(h.Get("Content-Length"), 10, 64)
+	if err != nil || n < 0 {
+		return def
+	}
+	return n
+}
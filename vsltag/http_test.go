@@ -0,0 +1,142 @@
+package vsltag_test
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	vsl "github.com/Showmax/vslparser"
+	"github.com/Showmax/vslparser/vsltag"
+)
+
+var httpRequestEntry = vsl.Entry{
+	Level: 1,
+	Kind:  vsl.KindRequest,
+	VXID:  32770,
+	Tags: []vsl.Tag{
+		{Key: "Begin", Value: "req 32769 rxreq"},
+		{Key: "ReqStart", Value: "127.0.0.1 37980 a0"},
+		{Key: "ReqMethod", Value: "PUT"},
+		{Key: "ReqURL", Value: "/foo?param=val"},
+		{Key: "ReqProtocol", Value: "HTTP/1.1"},
+		{Key: "ReqHeader", Value: "Host: localhost:6081"},
+		{Key: "ReqHeader", Value: "magic: aloha"},
+		{Key: "ReqHeader", Value: "Cookie: a=1"},
+		{Key: "ReqHeader", Value: "X-Forwarded-For: 127.0.0.1"},
+		{Key: "VCL_call", Value: "RECV"},
+		{Key: "ReqUnset", Value: "Cookie: a=1"},
+		{Key: "ReqUnset", Value: "magic: aloha"},
+		{Key: "ReqHeader", Value: "magic: mahalo"},
+		{Key: "ReqURL", Value: "/foo"},
+		{Key: "VCL_return", Value: "pass"},
+		{Key: "RespProtocol", Value: "HTTP/1.1"},
+		{Key: "RespStatus", Value: "503"},
+		{Key: "RespReason", Value: "Backend fetch failed"},
+		{Key: "RespHeader", Value: "Content-Type: text/html; charset=utf-8"},
+		{Key: "RespHeader", Value: "X-Varnish: 32770"},
+		{Key: "VCL_call", Value: "DELIVER"},
+		{Key: "RespUnset", Value: "X-Varnish: 32770"},
+		{Key: "VCL_return", Value: "deliver"},
+		{Key: "RespHeader", Value: "Content-Length: 282"},
+		{Key: "End", Value: ""},
+	},
+}
+
+func TestHTTPRequest(t *testing.T) {
+	req, err := vsltag.HTTPRequest(httpRequestEntry)
+	if err != nil {
+		t.Fatalf("HTTPRequest() failed: %v", err)
+	}
+
+	if req.Method != "PUT" {
+		t.Errorf("Method = %q, want %q", req.Method, "PUT")
+	}
+	if got := req.URL.String(); got != "/foo" {
+		t.Errorf("URL = %q, want %q", got, "/foo")
+	}
+	if req.Proto != "HTTP/1.1" || req.ProtoMajor != 1 || req.ProtoMinor != 1 {
+		t.Errorf("Proto = %q (%d.%d), want HTTP/1.1", req.Proto, req.ProtoMajor, req.ProtoMinor)
+	}
+	if req.Host != "localhost:6081" {
+		t.Errorf("Host = %q, want %q", req.Host, "localhost:6081")
+	}
+	if req.RemoteAddr != "127.0.0.1:37980" {
+		t.Errorf("RemoteAddr = %q, want %q", req.RemoteAddr, "127.0.0.1:37980")
+	}
+	want := http.Header{
+		"Magic":           {"mahalo"},
+		"X-Forwarded-For": {"127.0.0.1"},
+	}
+	if !reflect.DeepEqual(req.Header, want) {
+		t.Errorf("Header = %v, want %v", req.Header, want)
+	}
+}
+
+func TestHTTPResponse(t *testing.T) {
+	resp, err := vsltag.HTTPResponse(httpRequestEntry)
+	if err != nil {
+		t.Fatalf("HTTPResponse() failed: %v", err)
+	}
+
+	if resp.StatusCode != 503 || resp.Status != "503 Backend fetch failed" {
+		t.Errorf("Status = %d %q, want 503 %q", resp.StatusCode, resp.Status, "503 Backend fetch failed")
+	}
+	if resp.ContentLength != 282 {
+		t.Errorf("ContentLength = %d, want 282", resp.ContentLength)
+	}
+	want := http.Header{
+		"Content-Type":   {"text/html; charset=utf-8"},
+		"Content-Length": {"282"},
+	}
+	if !reflect.DeepEqual(resp.Header, want) {
+		t.Errorf("Header = %v, want %v", resp.Header, want)
+	}
+	if resp.Request == nil || resp.Request.Method != "PUT" {
+		t.Errorf("Request = %v, want the PUT request", resp.Request)
+	}
+}
+
+func TestHTTPRequest_BeReq(t *testing.T) {
+	e := vsl.Entry{
+		Level: 2,
+		Kind:  vsl.KindBeReq,
+		VXID:  3,
+		Tags: []vsl.Tag{
+			{Key: "Begin", Value: "bereq 2 fetch"},
+			{Key: "BereqMethod", Value: "GET"},
+			{Key: "BereqURL", Value: "/"},
+			{Key: "BereqProtocol", Value: "HTTP/1.1"},
+			{Key: "BereqHeader", Value: "Host: localhost:6081"},
+			{Key: "BereqHeader", Value: "Accept-Encoding: gzip"},
+			{Key: "BackendOpen", Value: "26 default 127.0.0.1 8080 127.0.0.1 45678 connect"},
+			{Key: "BerespProtocol", Value: "HTTP/1.1"},
+			{Key: "BerespStatus", Value: "200"},
+			{Key: "BerespReason", Value: "OK"},
+			{Key: "BerespHeader", Value: "Content-Length: 12"},
+			{Key: "End", Value: ""},
+		},
+	}
+
+	req, err := vsltag.HTTPRequest(e)
+	if err != nil {
+		t.Fatalf("HTTPRequest() failed: %v", err)
+	}
+	if req.Method != "GET" || req.Host != "localhost:6081" || req.Header.Get("Accept-Encoding") != "gzip" {
+		t.Errorf("unexpected request %+v", req)
+	}
+	if req.RemoteAddr != "127.0.0.1:45678" {
+		t.Errorf("RemoteAddr = %q, want %q", req.RemoteAddr, "127.0.0.1:45678")
+	}
+
+	resp, err := vsltag.HTTPResponse(e)
+	if err != nil {
+		t.Fatalf("HTTPResponse() failed: %v", err)
+	}
+	if resp.StatusCode != 200 || resp.ContentLength != 12 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	if _, err := vsltag.HTTPRequest(vsl.Entry{Kind: vsl.KindSession}); err == nil {
+		t.Errorf("HTTPRequest() of a session should fail")
+	}
+}
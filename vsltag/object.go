@@ -0,0 +1,181 @@
+package vsltag
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Showmax/vslparser"
+)
+
+// TTL stands for TTL set on object. Logged whenever TTL, grace or keep of an
+// object is set, by Varnish according to RFC 7234 headers ("RFC"), by VCL
+// ("VCL"), or when a hit-for-pass ("HFP") or hit-for-miss ("HFM") object is
+// created, e.g.
+//
+//	RFC 120 10 0 1604933732 1604933712 1604933730 0 120 cacheable
+//	VCL 300 10 0 1604933732 cacheable
+//
+// Origin (Age), Date, Expires and Max-Age fields are logged by RFC records
+// only. The trailing cacheable or uncacheable field is missing in older
+// Varnish versions.
+type TTL vslparser.Tag
+
+// Source returns the source of the TTL, e.g. "RFC" or "VCL".
+func (t TTL) Source() string {
+	sp := strings.SplitN(t.Value, " ", 2)
+	return sp[0]
+}
+
+func (t TTL) TTL() (time.Duration, error)   { return t.duration(1) }
+func (t TTL) Grace() (time.Duration, error) { return t.duration(2) }
+func (t TTL) Keep() (time.Duration, error)  { return t.duration(3) }
+
+// Reference returns the reference time the TTL is counted from.
+func (t TTL) Reference() (time.Time, error) { return t.time(4) }
+
+// HasHeaderFields reports whether the record holds Origin (Age), Date, Expires
+// and Max-Age fields.
+func (t TTL) HasHeaderFields() bool {
+	fields := strings.Fields(t.Value)
+	if t.hasCacheable(fields) {
+		fields = fields[:len(fields)-1]
+	}
+	return len(fields) >= 9
+}
+
+// Origin returns the time the object was created at the origin, i.e. the
+// reference time adjusted by the Age header of the backend response.
+func (t TTL) Origin() (time.Time, error) {
+	if !t.HasHeaderFields() {
+		return time.Time{}, ErrMissingField
+	}
+	return t.time(5)
+}
+
+// Age returns age of the object when it was received, including the Age
+// header of the backend response.
+func (t TTL) Age() (time.Duration, error) {
+	origin, err := t.Origin()
+	if err != nil {
+		return 0, err
+	}
+	ref, err := t.Reference()
+	if err != nil {
+		return 0, err
+	}
+	return ref.Sub(origin), nil
+}
+
+// Date returns the Date header of the backend response. It is the zero Time
+// if the header was missing.
+func (t TTL) Date() (time.Time, error) { return t.headerTime(6) }
+
+// Expires returns the Expires header of the backend response. It is the zero
+// Time if the header was missing.
+func (t TTL) Expires() (time.Time, error) { return t.headerTime(7) }
+
+// MaxAge returns max-age (or s-maxage) of the Cache-Control header of the
+// backend response. It is negative if the header was missing.
+func (t TTL) MaxAge() (time.Duration, error) { return t.headerDuration(8) }
+
+// Cacheable reports whether the object is cacheable. It fails with
+// ErrMissingField for Varnish versions which don't log it.
+func (t TTL) Cacheable() (bool, error) {
+	fields := strings.Fields(t.Value)
+	if !t.hasCacheable(fields) {
+		return false, ErrMissingField
+	}
+	return fields[len(fields)-1] == "cacheable", nil
+}
+
+func (t TTL) hasCacheable(fields []string) bool {
+	if len(fields) == 0 {
+		return false
+	}
+	last := fields[len(fields)-1]
+	return last == "cacheable" || last == "uncacheable"
+}
+
+func (t TTL) duration(i int) (time.Duration, error) {
+	return durationField(t.Value, i)
+}
+
+func (t TTL) time(i int) (time.Time, error) {
+	f, err := field(t.Value, i)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parseUnixFloat(f)
+}
+
+func (t TTL) headerDuration(i int) (time.Duration, error) {
+	if !t.HasHeaderFields() {
+		return 0, ErrMissingField
+	}
+	return t.duration(i)
+}
+
+func (t TTL) headerTime(i int) (time.Time, error) {
+	if !t.HasHeaderFields() {
+		return time.Time{}, ErrMissingField
+	}
+	f, _ := field(t.Value, i)
+	if f == "0" || strings.HasPrefix(f, "-") {
+		return time.Time{}, nil
+	}
+	return parseUnixFloat(f)
+}
+
+// Storage stands for where object is stored. Logged when storage for an
+// object is allocated, e.g. "malloc s0".
+type Storage vslparser.Tag
+
+// Type returns the storage type, e.g. "malloc" or "file".
+func (s Storage) Type() string {
+	sp := strings.SplitN(s.Value, " ", 2)
+	return sp[0]
+}
+
+// Name returns the name of the storage backend, e.g. "s0" or "Transient".
+func (s Storage) Name() (string, error) {
+	return field(s.Value, 1)
+}
+
+// ExpKill stands for object expiry event. Logged by the expiry thread when
+// objects are inserted into, expired from or evicted from the cache, e.g.
+//
+//	EXP_Expired x=32770 t=-2 h=0
+//	LRU x=32770
+type ExpKill vslparser.Tag
+
+// Event returns the kind of the event, e.g. "EXP_Expired" or "LRU".
+func (e ExpKill) Event() string {
+	sp := strings.SplitN(e.Value, " ", 2)
+	return sp[0]
+}
+
+// Field returns the value of a key=value field of the event, e.g. "x" holding
+// VXID of the object. The ok result is false if there is no such field.
+func (e ExpKill) Field(key string) (value string, ok bool) {
+	fields := strings.Fields(e.Value)
+	for i := 1; i < len(fields); i++ {
+		if f := fields[i]; strings.HasPrefix(f, key+"=") {
+			return f[len(key)+1:], true
+		}
+	}
+	return "", false
+}
+
+// VXID returns VXID of the object the event relates to.
+func (e ExpKill) VXID() (vslparser.VXID, error) {
+	x, ok := e.Field("x")
+	if !ok {
+		return 0, ErrMissingField
+	}
+	vxid, err := strconv.ParseUint(x, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return vslparser.VXID(vxid), nil
+}
@@ -0,0 +1,107 @@
+package vsltag_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Showmax/vslparser/vsltag"
+)
+
+func TestTTL(t *testing.T) {
+	ref := time.Date(2020, 11, 9, 14, 55, 32, 0, time.UTC)
+
+	rfc := vsltag.TTL{Key: "TTL", Value: "RFC 120 10 0 1604933732 1604933712 1604933730 0 -1 cacheable"}
+	if got := rfc.Source(); got != "RFC" {
+		t.Errorf("Source() = %q, want RFC", got)
+	}
+	if got, err := rfc.TTL(); err != nil || got != 120*time.Second {
+		t.Errorf("TTL() = %v, %v, want 2m", got, err)
+	}
+	if got, err := rfc.Grace(); err != nil || got != 10*time.Second {
+		t.Errorf("Grace() = %v, %v, want 10s", got, err)
+	}
+	if got, err := rfc.Keep(); err != nil || got != 0 {
+		t.Errorf("Keep() = %v, %v, want 0", got, err)
+	}
+	if got, err := rfc.Reference(); err != nil || !got.Equal(ref) {
+		t.Errorf("Reference() = %v, %v, want %v", got, err, ref)
+	}
+	if !rfc.HasHeaderFields() {
+		t.Errorf("HasHeaderFields() = false, want true")
+	}
+	if got, err := rfc.Age(); err != nil || got != 20*time.Second {
+		t.Errorf("Age() = %v, %v, want 20s", got, err)
+	}
+	if got, err := rfc.Date(); err != nil || !got.Equal(ref.Add(-2*time.Second)) {
+		t.Errorf("Date() = %v, %v, want %v", got, err, ref.Add(-2*time.Second))
+	}
+	if got, err := rfc.Expires(); err != nil || !got.IsZero() {
+		t.Errorf("Expires() = %v, %v, want zero Time", got, err)
+	}
+	if got, err := rfc.MaxAge(); err != nil || got != -time.Second {
+		t.Errorf("MaxAge() = %v, %v, want -1s", got, err)
+	}
+	if got, err := rfc.Cacheable(); err != nil || !got {
+		t.Errorf("Cacheable() = %v, %v, want true", got, err)
+	}
+
+	vcl := vsltag.TTL{Key: "TTL", Value: "VCL 300 10 0 1604933732 uncacheable"}
+	if vcl.HasHeaderFields() {
+		t.Errorf("HasHeaderFields() = true, want false")
+	}
+	if _, err := vcl.Age(); !errors.Is(err, vsltag.ErrMissingField) {
+		t.Errorf("Age() error = %v, want ErrMissingField", err)
+	}
+	if got, err := vcl.Cacheable(); err != nil || got {
+		t.Errorf("Cacheable() = %v, %v, want false", got, err)
+	}
+
+	// Varnish 6.0 doesn't log the cacheable field.
+	old := vsltag.TTL{Key: "TTL", Value: "RFC 120 10 0 1604933732 1604933732 1604933730 0 120"}
+	if got, err := old.MaxAge(); err != nil || got != 120*time.Second {
+		t.Errorf("MaxAge() = %v, %v, want 2m", got, err)
+	}
+	if _, err := old.Cacheable(); !errors.Is(err, vsltag.ErrMissingField) {
+		t.Errorf("Cacheable() error = %v, want ErrMissingField", err)
+	}
+
+	bad := vsltag.TTL{Key: "TTL", Value: "VCL x"}
+	if _, err := bad.TTL(); err == nil {
+		t.Errorf("TTL() of malformed value should fail")
+	}
+	if _, err := bad.Grace(); !errors.Is(err, vsltag.ErrMissingField) {
+		t.Errorf("Grace() error = %v, want ErrMissingField", err)
+	}
+}
+
+func TestStorage(t *testing.T) {
+	s := vsltag.Storage{Key: "Storage", Value: "malloc s0"}
+	if got := s.Type(); got != "malloc" {
+		t.Errorf("Type() = %q, want malloc", got)
+	}
+	if got, err := s.Name(); err != nil || got != "s0" {
+		t.Errorf("Name() = %q, %v, want s0", got, err)
+	}
+}
+
+func TestExpKill(t *testing.T) {
+	e := vsltag.ExpKill{Key: "ExpKill", Value: "EXP_Expired x=32770 t=-2 h=0"}
+	if got := e.Event(); got != "EXP_Expired" {
+		t.Errorf("Event() = %q, want EXP_Expired", got)
+	}
+	if got, ok := e.Field("t"); !ok || got != "-2" {
+		t.Errorf("Field(t) = %q, %v, want -2", got, ok)
+	}
+	if _, ok := e.Field("p"); ok {
+		t.Errorf("Field(p) reports missing field")
+	}
+	if got, err := e.VXID(); err != nil || got != 32770 {
+		t.Errorf("VXID() = %v, %v, want 32770", got, err)
+	}
+
+	e = vsltag.ExpKill{Key: "ExpKill", Value: "LRU_Exhausted"}
+	if _, err := e.VXID(); !errors.Is(err, vsltag.ErrMissingField) {
+		t.Errorf("VXID() error = %v, want ErrMissingField", err)
+	}
+}
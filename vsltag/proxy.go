@@ -0,0 +1,106 @@
+package vsltag
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/Showmax/vslparser"
+)
+
+// ErrProxyLocal is returned by Proxy accessors of addresses of a PROXY LOCAL
+// connection, e.g. a health check of the load balancer, which carries no
+// addresses.
+var ErrProxyLocal = errors.New("PROXY connection is local")
+
+// Proxy stands for PROXY protocol information. Logged for client connections
+// using the PROXY protocol, with the addresses sent by the proxy, e.g.
+// "2 1.2.3.4 5678 10.0.0.1 80".
+type Proxy vslparser.Tag
+
+// Version returns the PROXY protocol version, 1 or 2.
+func (p Proxy) Version() (int, error) { return intField(p.Value, 0) }
+
+// Local reports whether the connection was a PROXY LOCAL connection.
+func (p Proxy) Local() bool {
+	f, _ := field(p.Value, 1)
+	return f == "local"
+}
+
+// ClientAddr returns the address of the original client.
+func (p Proxy) ClientAddr() (addr net.IP, port int, err error) {
+	return p.addr(1)
+}
+
+// ServerAddr returns the address the original client connected to.
+func (p Proxy) ServerAddr() (addr net.IP, port int, err error) {
+	return p.addr(3)
+}
+
+func (p Proxy) addr(i int) (addr net.IP, port int, err error) {
+	if p.Local() {
+		return nil, 0, ErrProxyLocal
+	}
+	f, err := field(p.Value, i)
+	if err != nil {
+		return nil, 0, err
+	}
+	if addr = net.ParseIP(f); addr == nil {
+		return nil, 0, fmt.Errorf("invalid IP address %q", f)
+	}
+	if port, err = intField(p.Value, i+1); err != nil {
+		return nil, 0, err
+	}
+	return addr, port, nil
+}
+
+// RealClientAddr returns the address of the client of entries, e.g. of
+// a session group. If the connection used the PROXY protocol, the client
+// address sent by the proxy is returned rather than the address of the proxy
+// itself logged by SessOpen. ReqStart is used as a fallback for groups
+// without the session, e.g. request groups.
+func RealClientAddr(entries []vslparser.Entry) (addr net.IP, port int, err error) {
+	var sessOpen, reqStart *vslparser.Tag
+	for i := range entries {
+		for j := range entries[i].Tags {
+			tag := &entries[i].Tags[j]
+			switch tag.Key {
+			case vslparser.TagProxy:
+				if p := Proxy(*tag); !p.Local() {
+					return p.ClientAddr()
+				}
+			case vslparser.TagSessOpen:
+				if sessOpen == nil {
+					sessOpen = tag
+				}
+			case vslparser.TagReqStart:
+				if reqStart == nil {
+					reqStart = tag
+				}
+			}
+		}
+	}
+
+	switch {
+	case sessOpen != nil:
+		if len(strings.Fields(sessOpen.Value)) < 2 {
+			return nil, 0, fmt.Errorf("%w in SessOpen %q", ErrMissingField, sessOpen.Value)
+		}
+		addr, port = SessOpen(*sessOpen).RemoteAddr()
+		if addr == nil {
+			return nil, 0, fmt.Errorf("invalid SessOpen %q", sessOpen.Value)
+		}
+		return addr, port, nil
+	case reqStart != nil:
+		r := ReqStart(*reqStart)
+		if addr, err = r.ClientIP(); err != nil {
+			return nil, 0, err
+		}
+		if port, err = r.ClientPort(); err != nil {
+			return nil, 0, err
+		}
+		return addr, port, nil
+	}
+	return nil, 0, errors.New("no client address found")
+}
@@ -0,0 +1,67 @@
+package vsltag_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	vsl "github.com/Showmax/vslparser"
+	"github.com/Showmax/vslparser/vsltag"
+)
+
+func TestProxy(t *testing.T) {
+	p := vsltag.Proxy{Key: "Proxy", Value: "2 1.2.3.4 5678 10.0.0.1 80"}
+	if v, err := p.Version(); err != nil || v != 2 {
+		t.Errorf("Version() = %d, %v, want 2", v, err)
+	}
+	if addr, port, err := p.ClientAddr(); err != nil || !addr.Equal(net.IPv4(1, 2, 3, 4)) || port != 5678 {
+		t.Errorf("ClientAddr() = %v, %d, %v, want 1.2.3.4, 5678", addr, port, err)
+	}
+	if addr, port, err := p.ServerAddr(); err != nil || !addr.Equal(net.IPv4(10, 0, 0, 1)) || port != 80 {
+		t.Errorf("ServerAddr() = %v, %d, %v, want 10.0.0.1, 80", addr, port, err)
+	}
+
+	local := vsltag.Proxy{Key: "Proxy", Value: "2 local local local local"}
+	if !local.Local() {
+		t.Errorf("Local() = false, want true")
+	}
+	if _, _, err := local.ClientAddr(); !errors.Is(err, vsltag.ErrProxyLocal) {
+		t.Errorf("ClientAddr() error = %v, want ErrProxyLocal", err)
+	}
+}
+
+func TestRealClientAddr(t *testing.T) {
+	sess := vsl.Entry{Level: 1, Kind: vsl.KindSession, VXID: 1, Tags: []vsl.Tag{
+		{Key: "Begin", Value: "sess 0 PROXY"},
+		{Key: "SessOpen", Value: "10.0.0.2 40000 a1 10.0.0.1 80 1604933732.219939 25"},
+		{Key: "Proxy", Value: "2 1.2.3.4 5678 10.0.0.1 80"},
+		{Key: "Link", Value: "req 2 rxreq"},
+		{Key: "End", Value: ""},
+	}}
+	req := vsl.Entry{Level: 2, Kind: vsl.KindRequest, VXID: 2, Tags: []vsl.Tag{
+		{Key: "Begin", Value: "req 1 rxreq"},
+		{Key: "ReqStart", Value: "1.2.3.4 5678 a1"},
+		{Key: "End", Value: ""},
+	}}
+
+	tests := []struct {
+		name    string
+		entries []vsl.Entry
+		ip      net.IP
+		port    int
+	}{
+		{"proxy", []vsl.Entry{sess, req}, net.IPv4(1, 2, 3, 4), 5678},
+		{"session", []vsl.Entry{{Tags: sess.Tags[:2]}}, net.IPv4(10, 0, 0, 2), 40000},
+		{"request", []vsl.Entry{req}, net.IPv4(1, 2, 3, 4), 5678},
+	}
+	for _, tt := range tests {
+		addr, port, err := vsltag.RealClientAddr(tt.entries)
+		if err != nil || !addr.Equal(tt.ip) || port != tt.port {
+			t.Errorf("%s: RealClientAddr() = %v, %d, %v, want %v, %d", tt.name, addr, port, err, tt.ip, tt.port)
+		}
+	}
+
+	if _, _, err := vsltag.RealClientAddr(nil); err == nil {
+		t.Errorf("RealClientAddr() of no entries should fail")
+	}
+}
@@ -0,0 +1,228 @@
+package vsltag
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Showmax/vslparser"
+)
+
+// ErrNoRequest is returned by Summarize for groups without a client request.
+var ErrNoRequest = errors.New("no client request in group")
+
+// RequestSummary is a flat record of a client request with the fields most
+// dashboards need.
+//
+// Fields which are missing from the log, or logged malformed, are left zero.
+// Where a tag is logged repeatedly, the request fields (Method, Host, URL)
+// are taken from their first occurrence, i.e. as received from the client,
+// while the response fields (Status, byte counts) are taken from their last
+// occurrence, i.e. as delivered to the client. If the request was restarted,
+// the request fields come from the original request and the response fields
+// from the last restart, like varnishncsa does.
+type RequestSummary struct {
+	// VXID is VXID of the client request, not of its restarts.
+	VXID vslparser.VXID
+
+	// ClientIP and ClientPort are the address of the client as returned
+	// by RealClientAddr, i.e. taking PROXY protocol into account.
+	ClientIP   net.IP
+	ClientPort int
+
+	Method string
+	// Host is the Host header.
+	Host string
+	// URL is the URL including the query string.
+	URL string
+
+	// Status is the response status.
+	Status int
+	// BytesReceived and BytesSent are the total numbers of bytes received
+	// from and sent to the client, BodyBytesSent is the size of the
+	// response body.
+	BytesReceived, BytesSent, BodyBytesSent int64
+
+	// Outcome is the cache outcome as returned by CacheOutcome.
+	Outcome Outcome
+	// Backend is the name of the backend of the backend request started
+	// by the (last restart of the) request, including background fetches.
+	// It is empty if there was no backend request or if it's not part of
+	// the group. BackendStatus is the status the backend responded with,
+	// 0 if the fetch failed before the backend responded.
+	Backend       string
+	BackendStatus int
+
+	// TimeToFirstByte is the time from the start of the request to the
+	// start of delivery (the Process timestamp) and Duration is the time to
+	// the end of delivery (the Resp timestamp). Both span all restarts.
+	TimeToFirstByte time.Duration
+	Duration        time.Duration
+
+	// Restarts is the number of restarts of the request.
+	Restarts int
+	// ESIRequests is the number of ESI subrequests, including nested
+	// ones.
+	ESIRequests int
+}
+
+// Summarize summarizes the first client request of group, which is either
+// a request group (RequestParser) or a session group (SessionParser). Use
+// SummarizeAll to summarize all requests of a session group. It returns
+// ErrNoRequest if group contains no client request.
+func Summarize(group []vslparser.Entry) (RequestSummary, error) {
+	summaries := SummarizeAll(group)
+	if len(summaries) == 0 {
+		return RequestSummary{}, ErrNoRequest
+	}
+	return summaries[0], nil
+}
+
+// SummarizeAll summarizes each client request of group in the order they were
+// logged, i.e. a single request of a request group or all requests received
+// on the session of a session group. Restarts and ESI subrequests are
+// included in the summary of their client request.
+func SummarizeAll(group []vslparser.Entry) []RequestSummary {
+	var summaries []RequestSummary
+	tree := vslparser.NewTransactionTree(group)
+	tree.Walk(func(tx *vslparser.Transaction) bool {
+		if tx.Entry.Kind != vslparser.KindRequest ||
+			tx.Reason == vslparser.ReasonESI || tx.Reason == vslparser.ReasonRestart {
+			return true
+		}
+		summaries = append(summaries, summarize(requestEntries(tx)))
+		return true
+	})
+	return summaries
+}
+
+// requestEntries returns entries of the ancestors of tx (e.g. its session),
+// tx itself and its descendants.
+func requestEntries(tx *vslparser.Transaction) []vslparser.Entry {
+	var entries []vslparser.Entry
+	for p := tx.Parent; p != nil; p = p.Parent {
+		entries = append([]vslparser.Entry{p.Entry}, entries...)
+	}
+	tx.Walk(func(t *vslparser.Transaction) bool {
+		entries = append(entries, t.Entry)
+		return true
+	})
+	return entries
+}
+
+// summarize summarizes the first client request of entries.
+func summarize(entries []vslparser.Entry) RequestSummary {
+	chain := restartChain(entries)
+	first, last := chain[0], chain[len(chain)-1]
+
+	s := RequestSummary{
+		VXID:     first.VXID,
+		Restarts: len(chain) - 1,
+	}
+	s.ClientIP, s.ClientPort, _ = RealClientAddr(entries)
+
+	tags := vslparser.Tags(first.Tags)
+	if tag, ok := tags.FirstWithKey(vslparser.TagReqMethod); ok {
+		s.Method = tag.Value
+	}
+	if tag, ok := tags.FirstWithKey(vslparser.TagReqURL); ok {
+		s.URL = tag.Value
+	}
+	for _, tag := range first.Tags {
+		if tag.Key != vslparser.TagReqHeader {
+			continue
+		}
+		if h, ok := ParseHeader(tag); ok && h.Matches("Host") {
+			s.Host = h.Value()
+			break
+		}
+	}
+
+	for _, e := range chain {
+		for _, tag := range e.Tags {
+			switch tag.Key {
+			case vslparser.TagRespStatus:
+				if status, err := strconv.Atoi(tag.Value); err == nil {
+					s.Status = status
+				}
+			case vslparser.TagReqAcct:
+				if c, err := ReqAcct(tag).ByteCounts(); err == nil {
+					s.BytesReceived = c.TotalReceived
+					s.BytesSent = c.TotalTransmitted
+					s.BodyBytesSent = c.BodyTransmitted
+				}
+			}
+		}
+	}
+
+	res := CacheOutcome(entries)
+	s.Outcome = res.Outcome
+	for _, e := range entries {
+		switch {
+		case e.Kind == vslparser.KindBeReq && res.BeReqVXID != 0 && e.VXID == res.BeReqVXID:
+			s.Backend, s.BackendStatus = backendOf(e)
+		case e.Kind == vslparser.KindRequest && isESI(e):
+			s.ESIRequests++
+		}
+	}
+
+	start, ok := timestamp(first, vslparser.TimestampReqEventStart)
+	if !ok {
+		return s
+	}
+	if t, ok := timestamp(last, vslparser.TimestampReqEventProcess); ok {
+		s.TimeToFirstByte = t.Sub(start)
+	}
+	if t, ok := timestamp(last, vslparser.TimestampReqEventResp); ok {
+		s.Duration = t.Sub(start)
+	}
+	return s
+}
+
+// backendOf returns the backend name and response status of a BeReq. The
+// status is 0 if the fetch failed before the backend responded.
+func backendOf(e vslparser.Entry) (name string, status int) {
+	failed := false
+	for _, tag := range e.Tags {
+		switch tag.Key {
+		case vslparser.TagBackendOpen, vslparser.TagBackendReuse:
+			if fields := strings.Fields(tag.Value); len(fields) >= 2 {
+				name = fields[1]
+			}
+		case vslparser.TagFetchError:
+			if name == "" {
+				name = FetchError(tag).Backend()
+			}
+			failed = true
+		case vslparser.TagBerespStatus:
+			// Varnish logs a synthetic response if the fetch failed.
+			if v, err := strconv.Atoi(tag.Value); err == nil && !failed {
+				status = v
+			}
+		}
+	}
+	return name, status
+}
+
+// isESI reports whether e is an ESI subrequest.
+func isESI(e vslparser.Entry) bool {
+	tag, ok := vslparser.Tags(e.Tags).FirstWithKey(vslparser.TagBegin)
+	return ok && strings.HasSuffix(tag.Value, " "+vslparser.ReasonESI)
+}
+
+// timestamp returns time of the first Timestamp tag of event in e.
+func timestamp(e vslparser.Entry, event string) (time.Time, bool) {
+	for _, tag := range e.Tags {
+		if tag.Key != vslparser.TagTimestamp || !strings.HasPrefix(tag.Value, event+": ") {
+			continue
+		}
+		if len(strings.Fields(tag.Value)) < 2 {
+			return time.Time{}, false
+		}
+		t, err := Timestamp(tag).Time()
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
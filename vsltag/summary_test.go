@@ -0,0 +1,155 @@
+package vsltag_test
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	vsl "github.com/Showmax/vslparser"
+	"github.com/Showmax/vslparser/vsltag"
+)
+
+func entry(level int, kind string, vxid vsl.VXID, tags ...vsl.Tag) vsl.Entry {
+	return vsl.Entry{Level: level, Kind: kind, VXID: vxid, Tags: append(tags, vsl.Tag{Key: "End", Value: ""})}
+}
+
+func tag(key, value string) vsl.Tag { return vsl.Tag{Key: key, Value: value} }
+
+// sessionGroup is a session with a request which was restarted and fetched
+// a page with an ESI include, followed by a request hitting the cache.
+var sessionGroup = []vsl.Entry{
+	entry(1, vsl.KindSession, 1,
+		tag("Begin", "sess 0 HTTP/1"),
+		tag("SessOpen", "10.0.0.1 37976 a0 10.0.0.2 6081 1646693481.899000 23"),
+		tag("Link", "req 2 rxreq"),
+		tag("Link", "req 7 rxreq"),
+		tag("SessClose", "REM_CLOSE 1.000"),
+	),
+	entry(2, vsl.KindRequest, 2,
+		tag("Begin", "req 1 rxreq"),
+		tag("Timestamp", "Start: 1646693481.900000 0.000000 0.000000"),
+		tag("ReqStart", "10.0.0.1 37976 a0"),
+		tag("ReqMethod", "GET"),
+		tag("ReqURL", "/old?x=1"),
+		tag("ReqProtocol", "HTTP/1.1"),
+		tag("ReqHeader", "host: example.com"),
+		tag("VCL_call", "RECV"),
+		tag("ReqURL", "/new"),
+		tag("VCL_call", "SYNTH"),
+		tag("RespStatus", "301"),
+		tag("Timestamp", "Restart: 1646693481.901000 0.001000 0.001000"),
+		tag("Link", "req 3 restart"),
+	),
+	entry(3, vsl.KindRequest, 3,
+		tag("Begin", "req 2 restart"),
+		tag("Timestamp", "Start: 1646693481.901000 0.001000 0.000000"),
+		tag("ReqMethod", "GET"),
+		tag("ReqURL", "/new"),
+		tag("VCL_call", "MISS"),
+		tag("Link", "bereq 4 fetch"),
+		tag("Timestamp", "Process: 1646693481.910000 0.010000 0.009000"),
+		tag("RespStatus", "200"),
+		tag("Link", "req 5 esi"),
+		tag("Timestamp", "Resp: 1646693481.920000 0.020000 0.010000"),
+		tag("ReqAcct", "80 0 80 200 1000 1200"),
+	),
+	entry(3, vsl.KindBeReq, 4,
+		tag("Begin", "bereq 3 fetch"),
+		tag("BackendOpen", "26 origin 10.0.0.3 8080 10.0.0.2 45678 connect"),
+		tag("BerespStatus", "200"),
+	),
+	entry(3, vsl.KindRequest, 5,
+		tag("Begin", "req 3 esi"),
+		tag("ReqURL", "/fragment"),
+		tag("Link", "req 6 esi"),
+	),
+	entry(4, vsl.KindRequest, 6,
+		tag("Begin", "req 5 esi"),
+		tag("ReqURL", "/nested"),
+	),
+	entry(2, vsl.KindRequest, 7,
+		tag("Begin", "req 1 rxreq"),
+		tag("ReqMethod", "HEAD"),
+		tag("ReqURL", "/cached"),
+		tag("Hit", "4 100.000 10.000 0.000"),
+		tag("VCL_call", "HIT"),
+		tag("RespStatus", "200"),
+	),
+}
+
+func TestSummarizeAll(t *testing.T) {
+	got := vsltag.SummarizeAll(sessionGroup)
+	want := []vsltag.RequestSummary{
+		{
+			VXID:            2,
+			ClientIP:        net.ParseIP("10.0.0.1"),
+			ClientPort:      37976,
+			Method:          "GET",
+			Host:            "example.com",
+			URL:             "/old?x=1",
+			Status:          200,
+			BytesReceived:   80,
+			BytesSent:       1200,
+			BodyBytesSent:   1000,
+			Outcome:         vsltag.OutcomeMiss,
+			Backend:         "origin",
+			BackendStatus:   200,
+			TimeToFirstByte: 10 * time.Millisecond,
+			Duration:        20 * time.Millisecond,
+			Restarts:        1,
+			ESIRequests:     2,
+		},
+		{
+			VXID:       7,
+			ClientIP:   net.ParseIP("10.0.0.1"),
+			ClientPort: 37976,
+			Method:     "HEAD",
+			URL:        "/cached",
+			Status:     200,
+			Outcome:    vsltag.OutcomeHit,
+		},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("SummarizeAll() returned %d summaries, want %d", len(got), len(want))
+	}
+	for i := range want {
+		// Durations are computed from float timestamps.
+		got[i].TimeToFirstByte = got[i].TimeToFirstByte.Round(time.Microsecond)
+		got[i].Duration = got[i].Duration.Round(time.Microsecond)
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("summary %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	// Request group of the first request, i.e. without the session.
+	s, err := vsltag.Summarize(sessionGroup[1:6])
+	if err != nil {
+		t.Fatalf("Summarize() failed: %v", err)
+	}
+	if s.VXID != 2 || s.Restarts != 1 || s.Backend != "origin" || s.ESIRequests != 2 {
+		t.Errorf("Summarize() = %+v", s)
+	}
+	if !s.ClientIP.Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("ClientIP = %v, want 10.0.0.1 from ReqStart", s.ClientIP)
+	}
+
+	failed := []vsl.Entry{
+		requestWith(2, tag("VCL_call", "MISS"), tag("Link", "bereq 3 fetch"), tag("RespStatus", "503")),
+		entry(2, vsl.KindBeReq, 3,
+			tag("Begin", "bereq 2 fetch"),
+			tag("FetchError", "backend default: fail errno 111 (Connection refused)"),
+			tag("BerespStatus", "503"),
+		),
+	}
+	if s, _ := vsltag.Summarize(failed); s.Backend != "default" || s.BackendStatus != 0 || s.Status != 503 {
+		t.Errorf("Summarize() of failed fetch = %q, %d, %d, want default, 0, 503", s.Backend, s.BackendStatus, s.Status)
+	}
+
+	if _, err := vsltag.Summarize(sessionGroup[:1]); !errors.Is(err, vsltag.ErrNoRequest) {
+		t.Errorf("Summarize() of a session without requests = %v, want ErrNoRequest", err)
+	}
+}
@@ -1,6 +1,7 @@
 package vsltag
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"net"
@@ -35,7 +36,7 @@ func (l BackendOpen) RemoteAddr() (addr net.IP, port int) {
 }
 
 func (l BackendOpen) LocalAddr() (addr net.IP, port int) {
-	sp := strings.SplitN(l.Value, " ", 6)
+	sp := strings.SplitN(l.Value, " ", 7)
 	return net.ParseIP(sp[4]), parseInt(sp[5])
 }
 
@@ -93,6 +94,37 @@ func (l Link) Reason() string {
 	return l.Value[i+1:]
 }
 
+// ReqStart stands for Client request start. Start of request processing, it
+// holds the client address and the name of the listen socket.
+type ReqStart vslparser.Tag
+
+func (r ReqStart) ClientIP() (net.IP, error) {
+	f, err := field(r.Value, 0)
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(f)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address %q", f)
+	}
+	return ip, nil
+}
+
+func (r ReqStart) ClientPort() (int, error) {
+	f, err := field(r.Value, 1)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(f)
+}
+
+// Listener returns name of the listen socket the request was received on. It
+// is empty for Varnish versions which don't log it.
+func (r ReqStart) Listener() string {
+	f, _ := field(r.Value, 2)
+	return f
+}
+
 // ReqURL contains client request URL. The HTTP request URL.
 type ReqURL vslparser.Tag
 
@@ -187,6 +219,84 @@ func (h Hit) Keep() (float64, error) {
 	return strconv.ParseFloat(h.Value[i+1:], 64)
 }
 
+// ErrMissingField is returned by accessors of tags with fewer fields than
+// expected.
+var ErrMissingField = errors.New("missing field")
+
+// field returns i-th whitespace separated field of tag value s.
+func field(s string, i int) (string, error) {
+	fields := strings.Fields(s)
+	if i >= len(fields) {
+		return "", fmt.Errorf("%w %d in %q", ErrMissingField, i, s)
+	}
+	return fields[i], nil
+}
+
+// int64Field parses i-th whitespace separated field of tag value s.
+func int64Field(s string, i int) (int64, error) {
+	f, err := field(s, i)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(f, 10, 64)
+}
+
+// intField parses i-th whitespace separated field of tag value s.
+func intField(s string, i int) (int, error) {
+	f, err := field(s, i)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(f)
+}
+
+// durationField parses i-th whitespace separated field of tag value s holding
+// seconds.
+func durationField(s string, i int) (time.Duration, error) {
+	f, err := field(s, i)
+	if err != nil {
+		return 0, err
+	}
+	return parseDuration(f)
+}
+
+// HitMiss stands for Hit for miss object in cache. Object looked up in cache
+// was a hit-for-miss object, so the request is handled as a miss.
+type HitMiss vslparser.Tag
+
+func (h HitMiss) VXID() vslparser.VXID {
+	sp := strings.SplitN(h.Value, " ", 2)
+	return parseVXID(sp[0])
+}
+
+// TTL returns the remaining TTL of the hit-for-miss object in seconds.
+func (h HitMiss) TTL() (float64, error) {
+	f, err := field(h.Value, 1)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(f, 64)
+}
+
+// HitPass stands for Hit for pass object in cache. Object looked up in cache
+// was a hit-for-pass object, so the request is passed to the backend.
+type HitPass vslparser.Tag
+
+func (h HitPass) VXID() vslparser.VXID {
+	sp := strings.SplitN(h.Value, " ", 2)
+	return parseVXID(sp[0])
+}
+
+// TTL returns the remaining TTL of the hit-for-pass object in seconds. It
+// fails with ErrMissingField for Varnish versions which don't log it.
+func (h HitPass) TTL() (float64, error) {
+	f, err := field(h.Value, 1)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(f, 64)
+}
+
 func parseInt(s string) int {
 	i, err := strconv.Atoi(s)
 	if err != nil {
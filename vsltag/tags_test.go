@@ -83,3 +83,27 @@ func TestSessOpen(t *testing.T) {
 		})
 	}
 }
+
+func TestReqStart(t *testing.T) {
+	r := vsltag.ReqStart{Key: "ReqStart", Value: "127.0.0.1 37980 a0"}
+	if ip, err := r.ClientIP(); err != nil || !ip.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("ClientIP() = %v, %v, want 127.0.0.1", ip, err)
+	}
+	if port, err := r.ClientPort(); err != nil || port != 37980 {
+		t.Errorf("ClientPort() = %v, %v, want 37980", port, err)
+	}
+	if got := r.Listener(); got != "a0" {
+		t.Errorf("Listener() = %q, want a0", got)
+	}
+
+	r = vsltag.ReqStart{Key: "ReqStart", Value: "localhost"}
+	if _, err := r.ClientIP(); err == nil {
+		t.Errorf("ClientIP() of malformed value should fail")
+	}
+	if _, err := r.ClientPort(); err == nil {
+		t.Errorf("ClientPort() of missing value should fail")
+	}
+	if got := r.Listener(); got != "" {
+		t.Errorf("Listener() = %q, want empty", got)
+	}
+}
@@ -0,0 +1,241 @@
+package vsltag
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Showmax/vslparser"
+)
+
+// TimelineEvent is a single Timestamp tag of an Entry.
+type TimelineEvent struct {
+	// Name is the event name, e.g. "Start" or "Resp".
+	Name string
+	Time time.Time
+	// SinceStart is the time since the start of the transaction and
+	// SinceLast is the time since the previous event, as logged.
+	SinceStart, SinceLast time.Duration
+	// Index is the index of the tag in Entry.Tags.
+	Index int
+}
+
+// AnomalyKind is a kind of timeline anomaly.
+type AnomalyKind int
+
+const (
+	// AnomalyMissing means that an expected event is missing.
+	AnomalyMissing AnomalyKind = iota
+	// AnomalyOutOfOrder means that an event was logged earlier than an
+	// event which precedes it in request processing, or that its time is
+	// before the time of the previous event.
+	AnomalyOutOfOrder
+)
+
+func (k AnomalyKind) String() string {
+	switch k {
+	case AnomalyMissing:
+		return "missing"
+	case AnomalyOutOfOrder:
+		return "out of order"
+	}
+	return "unknown"
+}
+
+// TimelineAnomaly describes an event which is missing or out of order.
+type TimelineAnomaly struct {
+	Kind  AnomalyKind
+	Event string
+}
+
+func (a TimelineAnomaly) String() string {
+	return fmt.Sprintf("%s event %s", a.Kind, a.Event)
+}
+
+// timelineRanks order events of transaction kinds. An event must not be
+// logged after an event of higher rank. Events of the same rank may be logged
+// in any order, events without rank anywhere.
+var timelineRanks = map[string]map[string]int{
+	vslparser.KindRequest: {
+		vslparser.TimestampReqEventStart:       0,
+		vslparser.TimestampReqEventReq:         1,
+		vslparser.TimestampReqEventReqBody:     2,
+		vslparser.TimestampReqEventWaitinglist: 2,
+		vslparser.TimestampReqEventFetch:       2,
+		vslparser.TimestampReqEventPipe:        2,
+		vslparser.TimestampReqEventProcess:     3,
+		vslparser.TimestampReqEventResp:        4,
+		vslparser.TimestampReqEventRestart:     4,
+		vslparser.TimestampReqEventPipeSess:    4,
+	},
+	vslparser.KindBeReq: {
+		vslparser.TimestampBeReqEventStart:      0,
+		vslparser.TimestampBeReqEventBereq:      1,
+		vslparser.TimestampBeReqEventBeresp:     2,
+		vslparser.TimestampBeReqEventBerespBody: 3,
+		vslparser.TimestampBeReqEventRetry:      3,
+		vslparser.TimestampBeReqEventError:      3,
+	},
+}
+
+// timelineExpected lists events every transaction of a kind is expected to
+// log. Each item is a set of alternatives, the first one is reported as
+// missing.
+var timelineExpected = map[string][][]string{
+	vslparser.KindRequest: {
+		{vslparser.TimestampReqEventStart},
+		{vslparser.TimestampReqEventReq},
+		{
+			vslparser.TimestampReqEventResp,
+			vslparser.TimestampReqEventRestart,
+			vslparser.TimestampReqEventPipeSess,
+		},
+	},
+	vslparser.KindBeReq: {
+		{vslparser.TimestampBeReqEventStart},
+		{
+			vslparser.TimestampBeReqEventBerespBody,
+			vslparser.TimestampBeReqEventError,
+			vslparser.TimestampBeReqEventRetry,
+		},
+	},
+}
+
+// Timeline holds all Timestamp events of a Request or BeReq entry in the order
+// they were logged.
+type Timeline struct {
+	Events []TimelineEvent
+	// Anomalies are events which are missing or out of order.
+	Anomalies []TimelineAnomaly
+}
+
+// NewTimeline builds Timeline of e. It fails if any Timestamp tag is
+// malformed.
+func NewTimeline(e vslparser.Entry) (*Timeline, error) {
+	t := &Timeline{}
+	for i, tag := range e.Tags {
+		if tag.Key != vslparser.TagTimestamp {
+			continue
+		}
+		// The accessors of Timestamp expect all four fields.
+		if len(strings.Fields(tag.Value)) < 4 {
+			return nil, fmt.Errorf("invalid timestamp %q: %w", tag.Value, ErrMissingField)
+		}
+		ts := Timestamp(tag)
+		ev := TimelineEvent{Name: ts.Event(), Index: i}
+
+		var err error
+		if ev.Time, err = ts.Time(); err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q: %w", tag.Value, err)
+		}
+		if ev.SinceStart, err = ts.SinceStart(); err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q: %w", tag.Value, err)
+		}
+		if ev.SinceLast, err = ts.SinceLast(); err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q: %w", tag.Value, err)
+		}
+		t.Events = append(t.Events, ev)
+	}
+	t.checkOrder(timelineRanks[e.Kind])
+	t.checkMissing(timelineExpected[e.Kind])
+	return t, nil
+}
+
+func (t *Timeline) checkOrder(ranks map[string]int) {
+	maxRank := -1
+	for i, ev := range t.Events {
+		rank, ok := ranks[ev.Name]
+		if (ok && rank < maxRank) || (i > 0 && ev.Time.Before(t.Events[i-1].Time)) {
+			t.Anomalies = append(t.Anomalies, TimelineAnomaly{Kind: AnomalyOutOfOrder, Event: ev.Name})
+		}
+		if ok && rank > maxRank {
+			maxRank = rank
+		}
+	}
+}
+
+func (t *Timeline) checkMissing(expected [][]string) {
+	for _, alternatives := range expected {
+		found := false
+		for _, name := range alternatives {
+			if _, ok := t.Event(name); ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Anomalies = append(t.Anomalies, TimelineAnomaly{Kind: AnomalyMissing, Event: alternatives[0]})
+		}
+	}
+}
+
+// Event returns the first event called name.
+func (t *Timeline) Event(name string) (TimelineEvent, bool) {
+	for _, ev := range t.Events {
+		if ev.Name == name {
+			return ev, true
+		}
+	}
+	return TimelineEvent{}, false
+}
+
+// Between returns the time between the first events called from and to. The
+// ok result is false if any of them is missing.
+func (t *Timeline) Between(from, to string) (d time.Duration, ok bool) {
+	a, ok := t.Event(from)
+	if !ok {
+		return 0, false
+	}
+	b, ok := t.Event(to)
+	if !ok {
+		return 0, false
+	}
+	return b.Time.Sub(a.Time), true
+}
+
+// Total returns the time since the start of the transaction to the last
+// event.
+func (t *Timeline) Total() time.Duration {
+	if len(t.Events) == 0 {
+		return 0
+	}
+	return t.Events[len(t.Events)-1].SinceStart
+}
+
+// ReceiveTime returns the time spent receiving the client request headers
+// (Start to Req).
+func (t *Timeline) ReceiveTime() (time.Duration, bool) {
+	return t.Between(vslparser.TimestampReqEventStart, vslparser.TimestampReqEventReq)
+}
+
+// QueueTime returns the time the client request spent on the waiting list,
+// i.e. waiting for another request fetching the same object.
+func (t *Timeline) QueueTime() (time.Duration, bool) {
+	ev, ok := t.Event(vslparser.TimestampReqEventWaitinglist)
+	return ev.SinceLast, ok
+}
+
+// ProcessTime returns the time since the client request was received until
+// the response was ready to be delivered (Req to Process), including the
+// backend fetch if any.
+func (t *Timeline) ProcessTime() (time.Duration, bool) {
+	return t.Between(vslparser.TimestampReqEventReq, vslparser.TimestampReqEventProcess)
+}
+
+// DeliveryTime returns the time spent delivering the response to the client
+// (Process to Resp).
+func (t *Timeline) DeliveryTime() (time.Duration, bool) {
+	return t.Between(vslparser.TimestampReqEventProcess, vslparser.TimestampReqEventResp)
+}
+
+// BackendFirstByte returns the time from sending the backend request until
+// the response headers were received (Bereq to Beresp).
+func (t *Timeline) BackendFirstByte() (time.Duration, bool) {
+	return t.Between(vslparser.TimestampBeReqEventBereq, vslparser.TimestampBeReqEventBeresp)
+}
+
+// BackendBodyTime returns the time spent receiving the backend response body
+// (Beresp to BerespBody).
+func (t *Timeline) BackendBodyTime() (time.Duration, bool) {
+	return t.Between(vslparser.TimestampBeReqEventBeresp, vslparser.TimestampBeReqEventBerespBody)
+}
@@ -0,0 +1,115 @@
+package vsltag_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	vsl "github.com/Showmax/vslparser"
+	"github.com/Showmax/vslparser/vsltag"
+)
+
+func TestTimeline_Request(t *testing.T) {
+	e := vsl.Entry{Kind: vsl.KindRequest, Tags: []vsl.Tag{
+		{Key: "Begin", Value: "req 1 rxreq"},
+		{Key: "Timestamp", Value: "Start: 1604933732.000000 0.000000 0.000000"},
+		{Key: "Timestamp", Value: "Req: 1604933732.000100 0.000100 0.000100"},
+		{Key: "Timestamp", Value: "Waitinglist: 1604933732.002100 0.002100 0.002000"},
+		{Key: "Timestamp", Value: "Fetch: 1604933732.012100 0.012100 0.010000"},
+		{Key: "Timestamp", Value: "Process: 1604933732.012200 0.012200 0.000100"},
+		{Key: "Timestamp", Value: "Resp: 1604933732.015200 0.015200 0.003000"},
+		{Key: "End", Value: ""},
+	}}
+
+	tl, err := vsltag.NewTimeline(e)
+	if err != nil {
+		t.Fatalf("NewTimeline() failed: %v", err)
+	}
+	if len(tl.Events) != 6 || tl.Events[2].Name != "Waitinglist" || tl.Events[2].Index != 3 {
+		t.Errorf("unexpected events %+v", tl.Events)
+	}
+	if tl.Anomalies != nil {
+		t.Errorf("Anomalies = %v, want none", tl.Anomalies)
+	}
+
+	durations := []struct {
+		name string
+		get  func() (time.Duration, bool)
+		want time.Duration
+	}{
+		{"ReceiveTime", tl.ReceiveTime, 100 * time.Microsecond},
+		{"QueueTime", tl.QueueTime, 2 * time.Millisecond},
+		{"ProcessTime", tl.ProcessTime, 12100 * time.Microsecond},
+		{"DeliveryTime", tl.DeliveryTime, 3 * time.Millisecond},
+	}
+	for _, d := range durations {
+		if got, ok := d.get(); !ok || got != d.want {
+			t.Errorf("%s() = %v, %v, want %v", d.name, got, ok, d.want)
+		}
+	}
+	if _, ok := tl.BackendFirstByte(); ok {
+		t.Errorf("BackendFirstByte() of a client request should not be available")
+	}
+	if got := tl.Total(); got != 15200*time.Microsecond {
+		t.Errorf("Total() = %v, want 15.2ms", got)
+	}
+}
+
+func TestTimeline_BeReq(t *testing.T) {
+	e := vsl.Entry{Kind: vsl.KindBeReq, Tags: []vsl.Tag{
+		{Key: "Timestamp", Value: "Start: 1604933732.000000 0.000000 0.000000"},
+		{Key: "Timestamp", Value: "Bereq: 1604933732.001000 0.001000 0.001000"},
+		{Key: "Timestamp", Value: "Beresp: 1604933732.011000 0.011000 0.010000"},
+		{Key: "Timestamp", Value: "BerespBody: 1604933732.015000 0.015000 0.004000"},
+	}}
+
+	tl, err := vsltag.NewTimeline(e)
+	if err != nil {
+		t.Fatalf("NewTimeline() failed: %v", err)
+	}
+	if got, ok := tl.BackendFirstByte(); !ok || got != 10*time.Millisecond {
+		t.Errorf("BackendFirstByte() = %v, %v, want 10ms", got, ok)
+	}
+	if got, ok := tl.BackendBodyTime(); !ok || got != 4*time.Millisecond {
+		t.Errorf("BackendBodyTime() = %v, %v, want 4ms", got, ok)
+	}
+	if tl.Anomalies != nil {
+		t.Errorf("Anomalies = %v, want none", tl.Anomalies)
+	}
+}
+
+func TestTimeline_Anomalies(t *testing.T) {
+	e := vsl.Entry{Kind: vsl.KindRequest, Tags: []vsl.Tag{
+		{Key: "Timestamp", Value: "Req: 1604933732.000100 0.000100 0.000100"},
+		{Key: "Timestamp", Value: "Process: 1604933732.012200 0.012200 0.012100"},
+		{Key: "Timestamp", Value: "Fetch: 1604933732.012100 0.012100 0.010000"},
+	}}
+
+	tl, err := vsltag.NewTimeline(e)
+	if err != nil {
+		t.Fatalf("NewTimeline() failed: %v", err)
+	}
+	want := []vsltag.TimelineAnomaly{
+		{Kind: vsltag.AnomalyOutOfOrder, Event: "Fetch"},
+		{Kind: vsltag.AnomalyMissing, Event: "Start"},
+		{Kind: vsltag.AnomalyMissing, Event: "Resp"},
+	}
+	if !reflect.DeepEqual(tl.Anomalies, want) {
+		t.Errorf("Anomalies = %v, want %v", tl.Anomalies, want)
+	}
+
+	e.Tags = append(e.Tags, vsl.Tag{Key: "Timestamp", Value: "Resp: now"})
+	if _, err := vsltag.NewTimeline(e); err == nil {
+		t.Errorf("NewTimeline() with malformed timestamp should fail")
+	}
+}
+
+func TestTimeline_MissingFields(t *testing.T) {
+	for _, v := range []string{"Start:", "Start: 1646693544.293284", "Start: 1646693544.293284 0.000000"} {
+		e := vsl.Entry{Kind: vsl.KindRequest, Tags: []vsl.Tag{{Key: "Timestamp", Value: v}}}
+		if _, err := vsltag.NewTimeline(e); !errors.Is(err, vsltag.ErrMissingField) {
+			t.Errorf("NewTimeline() with timestamp %q error = %v, want ErrMissingField", v, err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package vsltag
+
+import (
+	"strings"
+
+	"github.com/Showmax/vslparser"
+)
+
+// VCLCall stands for VCL method called. Logged when a VCL subroutine (e.g.
+// vcl_recv) is entered.
+type VCLCall vslparser.Tag
+
+// Subroutine returns name of the VCL subroutine in upper case without the
+// "vcl_" prefix, e.g. "RECV".
+func (v VCLCall) Subroutine() string { return v.Value }
+
+// VCLReturn stands for VCL method return value. Logged when a VCL subroutine
+// returns.
+type VCLReturn vslparser.Tag
+
+// Action returns the return action, e.g. "hash" or "deliver".
+func (v VCLReturn) Action() string { return v.Value }
+
+// VCLLog contains a message logged by std.log() in VCL.
+type VCLLog vslparser.Tag
+
+func (v VCLLog) Message() string { return v.Value }
+
+// VCLError contains an error message of VCL execution, e.g. of a failed VMOD
+// call.
+type VCLError vslparser.Tag
+
+func (v VCLError) Message() string { return v.Value }
+
+// VCLACL stands for VCL ACL check results. Logged when an ACL is evaluated,
+// e.g. `MATCH purge "127.0.0.1"` or `NO_MATCH purge`.
+type VCLACL vslparser.Tag
+
+// Result returns the result of the check, e.g. "MATCH" or "NO_MATCH".
+func (v VCLACL) Result() string {
+	sp := strings.SplitN(v.Value, " ", 2)
+	return sp[0]
+}
+
+// Matched reports whether the address matched the ACL.
+func (v VCLACL) Matched() bool { return v.Result() == "MATCH" }
+
+// Name returns name of the ACL.
+func (v VCLACL) Name() (string, error) {
+	return field(v.Value, 1)
+}
+
+// Pattern returns the ACL entry which matched with quotes removed, e.g.
+// "10.0.0.0/8" for `"10.0.0.0"/8`. It is empty if there was no match.
+func (v VCLACL) Pattern() string {
+	sp := strings.SplitN(v.Value, " ", 3)
+	if len(sp) < 3 {
+		return ""
+	}
+	return strings.ReplaceAll(sp[2], `"`, "")
+}
+
+// VCLInvocation is a single invocation of a VCL subroutine.
+type VCLInvocation struct {
+	// Sub is the subroutine name as logged by VCL_call, e.g. "RECV".
+	Sub string
+	// Return is the return action as logged by VCL_return, e.g. "hash".
+	// It is empty if the subroutine didn't return, e.g. because of a VCL
+	// failure.
+	Return string
+	// Logs are messages logged by std.log() in the subroutine.
+	Logs []string
+	// Errors are VCL errors which occurred in the subroutine.
+	Errors []string
+	// ACLs are results of ACL checks evaluated by the subroutine.
+	ACLs []VCLACL
+	// Start is the index of the VCL_call tag in Entry.Tags and End is the
+	// index of the VCL_return tag, or of the tag which ended the
+	// invocation otherwise.
+	Start, End int
+}
+
+// VCLTrace returns VCL subroutine invocations of e in the order they were
+// called, with the VCL_Log, VCL_Error and VCL_acl tags logged during each
+// invocation. Such tags logged outside of any invocation are ignored.
+func VCLTrace(e vslparser.Entry) []VCLInvocation {
+	var trace []VCLInvocation
+	for _, s := range vclStages(e.Tags) {
+		inv := VCLInvocation{
+			Sub:    s.name,
+			Return: s.ret,
+			Start:  s.start,
+			End:    s.end,
+		}
+		for _, tag := range e.Tags[s.start+1 : s.end] {
+			switch tag.Key {
+			case vslparser.TagVCLLog:
+				inv.Logs = append(inv.Logs, VCLLog(tag).Message())
+			case vslparser.TagVCLError:
+				inv.Errors = append(inv.Errors, VCLError(tag).Message())
+			case vslparser.TagVCLACL:
+				inv.ACLs = append(inv.ACLs, VCLACL(tag))
+			}
+		}
+		trace = append(trace, inv)
+	}
+	return trace
+}
+
+// vclStage is a single VCL subroutine invocation. end is the index of the
+// VCL_return tag, or of the tag which ended the invocation otherwise.
+type vclStage struct {
+	name, ret  string
+	start, end int
+}
+
+// vclStages splits tags into VCL subroutine invocations delimited by VCL_call
+// and VCL_return tags. An invocation which didn't return is ended by the next
+// VCL_call tag or by the end of tags.
+func vclStages(tags []vslparser.Tag) []vclStage {
+	var stages []vclStage
+
+	current := -1 // index of the running stage in stages
+	for i, tag := range tags {
+		switch tag.Key {
+		case vslparser.TagVCLCall:
+			if current >= 0 {
+				stages[current].end = i
+			}
+			stages = append(stages, vclStage{
+				name:  VCLCall(tag).Subroutine(),
+				start: i,
+				end:   len(tags),
+			})
+			current = len(stages) - 1
+		case vslparser.TagVCLReturn:
+			if current >= 0 {
+				stages[current].ret = VCLReturn(tag).Action()
+				stages[current].end = i
+				current = -1
+			}
+		}
+	}
+	return stages
+}
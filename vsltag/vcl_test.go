@@ -0,0 +1,79 @@
+package vsltag_test
+
+import (
+	"reflect"
+	"testing"
+
+	vsl "github.com/Showmax/vslparser"
+	"github.com/Showmax/vslparser/vsltag"
+)
+
+func TestVCLACL(t *testing.T) {
+	tests := []struct {
+		value   string
+		matched bool
+		name    string
+		pattern string
+	}{
+		{`MATCH purge "127.0.0.1"`, true, "purge", "127.0.0.1"},
+		{`MATCH local "10.0.0.0"/8`, true, "local", "10.0.0.0/8"},
+		{`NO_MATCH purge`, false, "purge", ""},
+	}
+	for _, tt := range tests {
+		acl := vsltag.VCLACL{Key: "VCL_acl", Value: tt.value}
+		if got := acl.Matched(); got != tt.matched {
+			t.Errorf("%q: Matched() = %v, want %v", tt.value, got, tt.matched)
+		}
+		if got, err := acl.Name(); err != nil || got != tt.name {
+			t.Errorf("%q: Name() = %q, %v, want %q", tt.value, got, err, tt.name)
+		}
+		if got := acl.Pattern(); got != tt.pattern {
+			t.Errorf("%q: Pattern() = %q, want %q", tt.value, got, tt.pattern)
+		}
+	}
+
+	if _, err := (vsltag.VCLACL{Key: "VCL_acl", Value: "MATCH"}).Name(); err == nil {
+		t.Errorf("Name() of ACL without name should fail")
+	}
+}
+
+func TestVCLTrace(t *testing.T) {
+	e := vsl.Entry{
+		Kind: vsl.KindRequest,
+		Tags: []vsl.Tag{
+			{Key: "Begin", Value: "req 1 rxreq"},
+			{Key: "VCL_call", Value: "RECV"},
+			{Key: "VCL_acl", Value: `MATCH purge "127.0.0.1"`},
+			{Key: "VCL_Log", Value: "purging"},
+			{Key: "VCL_return", Value: "purge"},
+			{Key: "VCL_call", Value: "HASH"},
+			{Key: "VCL_return", Value: "lookup"},
+			{Key: "VCL_call", Value: "SYNTH"},
+			{Key: "VCL_Error", Value: "vmod failed"},
+			{Key: "VCL_Log", Value: "oops"},
+			{Key: "End", Value: ""},
+		},
+	}
+
+	want := []vsltag.VCLInvocation{
+		{
+			Sub:    "RECV",
+			Return: "purge",
+			Logs:   []string{"purging"},
+			ACLs:   []vsltag.VCLACL{{Key: "VCL_acl", Value: `MATCH purge "127.0.0.1"`}},
+			Start:  1,
+			End:    4,
+		},
+		{Sub: "HASH", Return: "lookup", Start: 5, End: 6},
+		{
+			Sub:    "SYNTH",
+			Logs:   []string{"oops"},
+			Errors: []string{"vmod failed"},
+			Start:  7,
+			End:    11,
+		},
+	}
+	if got := vsltag.VCLTrace(e); !reflect.DeepEqual(got, want) {
+		t.Errorf("VCLTrace() = %+v, want %+v", got, want)
+	}
+}
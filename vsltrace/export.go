@@ -0,0 +1,189 @@
+package vsltrace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Showmax/vslparser"
+)
+
+// Exporter sends spans to a tracing backend.
+type Exporter interface {
+	// ExportSpans exports a batch of spans. It may be called
+	// concurrently.
+	ExportSpans(ctx context.Context, spans []Span) error
+}
+
+// Export converts a group of entries into spans (see Spans) and exports them
+// by exp.
+func Export(ctx context.Context, exp Exporter, entries []vslparser.Entry) error {
+	spans, err := Spans(entries)
+	if err != nil {
+		return err
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+	return exp.ExportSpans(ctx, spans)
+}
+
+// scopeName is the instrumentation scope of exported spans.
+const scopeName = "github.com/Showmax/vslparser/vsltrace"
+
+// OTLPJSONWriter is an Exporter writing spans in OTLP/JSON encoding, one
+// ExportTraceServiceRequest per line, as read e.g. by the otlpjsonfile
+// receiver of the OpenTelemetry Collector.
+type OTLPJSONWriter struct {
+	mu          sync.Mutex
+	w           io.Writer
+	serviceName string
+}
+
+// NewOTLPJSONWriter creates OTLPJSONWriter writing to w. The serviceName is
+// used as the service.name resource attribute.
+func NewOTLPJSONWriter(w io.Writer, serviceName string) *OTLPJSONWriter {
+	return &OTLPJSONWriter{w: w, serviceName: serviceName}
+}
+
+// ExportSpans writes spans as a single line.
+func (w *OTLPJSONWriter) ExportSpans(ctx context.Context, spans []Span) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	req := otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: []otlpKeyValue{otlpAttribute(Attribute{"service.name", w.serviceName})},
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: scopeName},
+				Spans: make([]otlpSpan, 0, len(spans)),
+			}},
+		}},
+	}
+	scope := &req.ResourceSpans[0].ScopeSpans[0]
+	for _, s := range spans {
+		scope.Spans = append(scope.Spans, newOTLPSpan(s))
+	}
+
+	b, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("cannot encode spans: %w", err)
+	}
+	b = append(b, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.w.Write(b)
+	return err
+}
+
+// Types below mirror the JSON mapping of OTLP protobuf messages. Trace and
+// span IDs are hex encoded and 64-bit integers are strings.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Message string `json:"message,omitempty"`
+	Code    int    `json:"code"`
+}
+
+// otlpStatusCodeError is STATUS_CODE_ERROR.
+const otlpStatusCodeError = 2
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+func newOTLPSpan(s Span) otlpSpan {
+	span := otlpSpan{
+		TraceID:           s.TraceID.String(),
+		SpanID:            s.SpanID.String(),
+		Name:              s.Name,
+		Kind:              s.Kind,
+		StartTimeUnixNano: unixNano(s.Start),
+		EndTimeUnixNano:   unixNano(s.End),
+	}
+	if s.ParentSpanID.IsValid() {
+		span.ParentSpanID = s.ParentSpanID.String()
+	}
+	for _, a := range s.Attributes {
+		span.Attributes = append(span.Attributes, otlpAttribute(a))
+	}
+	if s.Error {
+		span.Status = &otlpStatus{Message: s.StatusMessage, Code: otlpStatusCodeError}
+	}
+	return span
+}
+
+func otlpAttribute(a Attribute) otlpKeyValue {
+	kv := otlpKeyValue{Key: a.Key}
+	switch v := a.Value.(type) {
+	case string:
+		kv.Value.StringValue = &v
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		kv.Value.IntValue = &s
+	case float64:
+		kv.Value.DoubleValue = &v
+	case bool:
+		kv.Value.BoolValue = &v
+	default:
+		s := fmt.Sprint(v)
+		kv.Value.StringValue = &s
+	}
+	return kv
+}
+
+func unixNano(t time.Time) string {
+	if t.IsZero() {
+		return "0"
+	}
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
@@ -0,0 +1,86 @@
+package vsltrace_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/Showmax/vslparser/internal/testlog"
+	"github.com/Showmax/vslparser/vsltrace"
+)
+
+func TestOTLPJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := vsltrace.NewOTLPJSONWriter(&buf, "varnish")
+	if err := vsltrace.Export(context.Background(), w, testlog.RequestGroups(t)[0]); err != nil {
+		t.Fatalf("Export() failed: %v", err)
+	}
+
+	var got struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []struct {
+					Key   string
+					Value struct{ StringValue string }
+				}
+			}
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID           string
+					SpanID            string
+					ParentSpanID      string
+					Name              string
+					Kind              int
+					StartTimeUnixNano string
+					EndTimeUnixNano   string
+					Attributes        []struct {
+						Key   string
+						Value map[string]interface{}
+					}
+					Status struct {
+						Code    int
+						Message string
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("cannot decode output %q: %v", buf.String(), err)
+	}
+	if bytes.Count(buf.Bytes(), []byte("\n")) != 1 {
+		t.Errorf("output is not a single line: %q", buf.String())
+	}
+
+	rs := got.ResourceSpans[0]
+	if a := rs.Resource.Attributes[0]; a.Key != "service.name" || a.Value.StringValue != "varnish" {
+		t.Errorf("unexpected resource attribute %+v", a)
+	}
+	spans := rs.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	req, bereq := spans[0], spans[1]
+	if len(req.TraceID) != 32 || len(req.SpanID) != 16 || req.ParentSpanID != "" {
+		t.Errorf("unexpected request span IDs %q %q %q", req.TraceID, req.SpanID, req.ParentSpanID)
+	}
+	if bereq.ParentSpanID != req.SpanID || bereq.Kind != 3 {
+		t.Errorf("unexpected backend request span %+v", bereq)
+	}
+	if req.StartTimeUnixNano != "1646693481899847000" || req.EndTimeUnixNano != "1646693481900513000" {
+		t.Errorf("unexpected request span timing %s - %s", req.StartTimeUnixNano, req.EndTimeUnixNano)
+	}
+	if a := req.Attributes[0]; a.Key != "varnish.vxid" || a.Value["intValue"] != "2" {
+		t.Errorf("unexpected attribute %+v", a)
+	}
+	if bereq.Status.Code != 2 || bereq.Status.Message == "" {
+		t.Errorf("unexpected backend request span status %+v", bereq.Status)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := w.ExportSpans(ctx, nil); err == nil {
+		t.Errorf("ExportSpans() with canceled context should fail")
+	}
+}
@@ -0,0 +1,378 @@
+// Package vsltrace converts transactions parsed by vslparser into trace spans
+// compatible with OpenTelemetry, so that Varnish requests can be shown in
+// distributed traces.
+//
+// Every transaction of a group (a Session, client Requests including ESI
+// subrequests and restarts, and BeReqs) becomes a span. Parent/child
+// relationships are taken from Begin and Link tags, span timing from
+// Timestamp tags. If the first client request of a group carries a W3C
+// traceparent header, its spans join the trace of the caller, otherwise
+// a trace ID is derived from the group, so that repeated conversion of the
+// same log produces the same IDs.
+package vsltrace
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Showmax/vslparser"
+	"github.com/Showmax/vslparser/vsltag"
+)
+
+// TraceID is a 16 bytes trace identifier.
+type TraceID [16]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether id is not all zeros.
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// SpanID is an 8 bytes span identifier.
+type SpanID [8]byte
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether id is not all zeros.
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// SpanKind is the kind of a span, with values of OpenTelemetry.
+type SpanKind int
+
+const (
+	// SpanKindInternal is used for sessions.
+	SpanKindInternal SpanKind = 1
+	// SpanKindServer is used for client requests.
+	SpanKindServer SpanKind = 2
+	// SpanKindClient is used for backend requests.
+	SpanKindClient SpanKind = 3
+)
+
+// Attribute is a span attribute. Value is a string, int64, float64 or bool.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Span is a single transaction converted to a trace span.
+type Span struct {
+	TraceID TraceID
+	SpanID  SpanID
+	// ParentSpanID is the ID of the parent span. For the root span, it is
+	// the span ID of the traceparent header or zero if there was none.
+	ParentSpanID SpanID
+	Name         string
+	Kind         SpanKind
+	Start, End   time.Time
+	Attributes   []Attribute
+	// Error tells whether the transaction failed and StatusMessage
+	// describes the failure.
+	Error         bool
+	StatusMessage string
+}
+
+// Attribute returns value of attribute key.
+func (s *Span) Attribute(key string) (interface{}, bool) {
+	for _, a := range s.Attributes {
+		if a.Key == key {
+			return a.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Spans converts a group of entries, e.g. a result of RequestParser.Parse or
+// SessionParser.Parse, into spans in depth-first order. Each root of the
+// transaction tree of the group (see vslparser.NewTransactionTree) starts
+// a trace of its own.
+func Spans(entries []vslparser.Entry) ([]Span, error) {
+	tree := vslparser.NewTransactionTree(entries)
+
+	var spans []Span
+	for _, root := range tree.Roots {
+		b := &builder{}
+		if err := b.build(root); err != nil {
+			return nil, err
+		}
+		b.assignIDs(root)
+		spans = append(spans, b.spans...)
+	}
+	return spans, nil
+}
+
+// builder converts a single transaction tree into spans.
+type builder struct {
+	spans []Span
+	// txs are transactions of spans.
+	txs []*vslparser.Transaction
+}
+
+// build appends spans of tx and its descendants. Spans of transactions
+// without timing information span their children.
+func (b *builder) build(tx *vslparser.Transaction) error {
+	i := len(b.spans)
+	span, err := newSpan(tx)
+	if err != nil {
+		return err
+	}
+	b.spans = append(b.spans, span)
+	b.txs = append(b.txs, tx)
+
+	timed := !span.Start.IsZero()
+	for _, c := range tx.Children {
+		j := len(b.spans)
+		if err := b.build(c); err != nil {
+			return err
+		}
+		if timed {
+			continue
+		}
+		s, child := &b.spans[i], b.spans[j]
+		if s.Start.IsZero() || (!child.Start.IsZero() && child.Start.Before(s.Start)) {
+			s.Start = child.Start
+		}
+		if child.End.After(s.End) {
+			s.End = child.End
+		}
+	}
+	return nil
+}
+
+// assignIDs sets trace and span IDs of all spans of the tree rooted at root.
+func (b *builder) assignIDs(root *vslparser.Transaction) {
+	traceID, parent, ok := traceContext(root)
+	if !ok {
+		traceID = deterministicTraceID(root.Entry.VXID, b.spans[0].Start)
+	}
+
+	ids := make(map[*vslparser.Transaction]SpanID, len(b.txs))
+	for i, tx := range b.txs {
+		id := deterministicSpanID(traceID, tx.Entry.VXID)
+		ids[tx] = id
+		b.spans[i].TraceID = traceID
+		b.spans[i].SpanID = id
+		if i == 0 {
+			b.spans[i].ParentSpanID = parent
+		} else {
+			b.spans[i].ParentSpanID = ids[tx.Parent]
+		}
+	}
+}
+
+// newSpan creates a span of a single transaction without IDs.
+func newSpan(tx *vslparser.Transaction) (Span, error) {
+	e := tx.Entry
+	span := Span{Attributes: []Attribute{{"varnish.vxid", int64(e.VXID)}}}
+	if tx.Reason != "" {
+		span.Attributes = append(span.Attributes, Attribute{"varnish.reason", tx.Reason})
+	}
+
+	switch e.Kind {
+	case vslparser.KindSession:
+		span.Name, span.Kind = "session", SpanKindInternal
+		if err := sessionTiming(&span, e); err != nil {
+			return Span{}, fmt.Errorf("VXID %d: %w", e.VXID, err)
+		}
+		if addr, _, err := vsltag.RealClientAddr([]vslparser.Entry{e}); err == nil {
+			span.Attributes = append(span.Attributes, Attribute{"client.address", addr.String()})
+		}
+		return span, nil
+	case vslparser.KindRequest:
+		span.Kind = SpanKindServer
+	case vslparser.KindBeReq:
+		span.Kind = SpanKindClient
+	default:
+		span.Name, span.Kind = strings.ToLower(e.Kind), SpanKindInternal
+		return span, nil
+	}
+
+	tl, err := vsltag.NewTimeline(e)
+	if err != nil {
+		return Span{}, fmt.Errorf("VXID %d: %w", e.VXID, err)
+	}
+	if len(tl.Events) > 0 {
+		span.Start = tl.Events[0].Time
+		span.End = tl.Events[len(tl.Events)-1].Time
+	}
+
+	if e.Kind == vslparser.KindRequest {
+		requestAttributes(&span, tx)
+	} else {
+		bereqAttributes(&span, e)
+	}
+	return span, nil
+}
+
+// sessionTiming sets span timing from SessOpen and SessClose.
+func sessionTiming(span *Span, e vslparser.Entry) error {
+	tags := vslparser.Tags(e.Tags)
+	open, ok := tags.FirstWithKey(vslparser.TagSessOpen)
+	if !ok || len(strings.Fields(open.Value)) < 6 {
+		return nil
+	}
+	start, err := vsltag.SessOpen(open).SessionStart()
+	if err != nil {
+		return err
+	}
+	span.Start, span.End = start, start
+	if tag, ok := tags.LastWithKey(vslparser.TagSessClose); ok {
+		d, err := vsltag.SessClose(tag).Duration()
+		if err != nil {
+			return err
+		}
+		span.End = start.Add(d)
+	}
+	return nil
+}
+
+func requestAttributes(span *Span, tx *vslparser.Transaction) {
+	tags := vslparser.Tags(tx.Entry.Tags)
+	span.Name = httpAttributes(span, tags, vslparser.TagReqMethod, vslparser.TagReqURL, vslparser.TagRespStatus)
+
+	if tag, ok := tags.FirstWithKey(vslparser.TagReqStart); ok {
+		if ip, err := vsltag.ReqStart(tag).ClientIP(); err == nil {
+			span.Attributes = append(span.Attributes, Attribute{"client.address", ip.String()})
+		}
+	}
+
+	var group []vslparser.Entry
+	tx.Walk(func(t *vslparser.Transaction) bool {
+		group = append(group, t.Entry)
+		return true
+	})
+	if res := vsltag.CacheOutcome(group); res.Outcome != vsltag.OutcomeUnknown {
+		span.Attributes = append(span.Attributes, Attribute{"varnish.cache.outcome", res.Outcome.String()})
+	}
+}
+
+func bereqAttributes(span *Span, e vslparser.Entry) {
+	tags := vslparser.Tags(e.Tags)
+	span.Name = httpAttributes(span, tags, vslparser.TagBeReqMethod, vslparser.TagBeReqURL, vslparser.TagBerespStatus)
+
+	if tag, ok := tags.LastWithKey(vslparser.TagBackendOpen); ok && len(strings.Fields(tag.Value)) >= 4 {
+		o := vsltag.BackendOpen(tag)
+		addr, port := o.RemoteAddr()
+		span.Attributes = append(span.Attributes,
+			Attribute{"varnish.backend.name", o.Name()},
+			Attribute{"server.address", addr.String()},
+			Attribute{"server.port", int64(port)},
+		)
+	}
+	if tag, ok := tags.LastWithKey(vslparser.TagFetchError); ok {
+		span.Error = true
+		span.StatusMessage = vsltag.FetchError(tag).Message()
+	}
+}
+
+// httpAttributes adds HTTP attributes of a request and returns the span name.
+// The method and URL are taken as received, the status as sent. Responses
+// with 5xx status are errors.
+func httpAttributes(span *Span, tags vslparser.Tags, methodKey, urlKey, statusKey string) string {
+	name := "HTTP"
+	if tag, ok := tags.FirstWithKey(methodKey); ok {
+		name = tag.Value
+		span.Attributes = append(span.Attributes, Attribute{"http.request.method", tag.Value})
+	}
+	if tag, ok := tags.FirstWithKey(urlKey); ok {
+		path, query := tag.Value, ""
+		if i := strings.IndexByte(path, '?'); i >= 0 {
+			path, query = path[:i], path[i+1:]
+		}
+		span.Attributes = append(span.Attributes, Attribute{"url.path", path})
+		if query != "" {
+			span.Attributes = append(span.Attributes, Attribute{"url.query", query})
+		}
+	}
+	if tag, ok := tags.LastWithKey(statusKey); ok {
+		status, _ := strconv.Atoi(tag.Value)
+		span.Attributes = append(span.Attributes, Attribute{"http.response.status_code", int64(status)})
+		if status >= 500 {
+			span.Error = true
+		}
+	}
+	return name
+}
+
+// traceContext returns trace ID and parent span ID of the traceparent header
+// of the first client request of the tree rooted at root.
+func traceContext(root *vslparser.Transaction) (traceID TraceID, parent SpanID, ok bool) {
+	root.Walk(func(tx *vslparser.Transaction) bool {
+		if tx.Entry.Kind != vslparser.KindRequest {
+			return true
+		}
+		for _, tag := range tx.Entry.Tags {
+			if tag.Key != vslparser.TagReqHeader {
+				continue
+			}
+			if h, isHeader := vsltag.ParseHeader(tag); isHeader && h.Matches("traceparent") {
+				traceID, parent, ok = ParseTraceparent(h.Value())
+				break
+			}
+		}
+		return false
+	})
+	return traceID, parent, ok
+}
+
+// ParseTraceparent parses the value of a W3C Trace Context traceparent header,
+// e.g. "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01". The ok
+// result is false if the value is invalid.
+func ParseTraceparent(s string) (traceID TraceID, parent SpanID, ok bool) {
+	// Future versions may append more fields, version 00 may not.
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || (parts[0] == "00" && len(parts) != 4) || parts[0] == "ff" {
+		return TraceID{}, SpanID{}, false
+	}
+	version, trace, span, flags := parts[0], parts[1], parts[2], parts[3]
+	if len(version) != 2 || len(trace) != 32 || len(span) != 16 || len(flags) != 2 {
+		return TraceID{}, SpanID{}, false
+	}
+	if _, err := hex.DecodeString(version + flags); err != nil {
+		return TraceID{}, SpanID{}, false
+	}
+	if _, err := hex.Decode(traceID[:], []byte(trace)); err != nil {
+		return TraceID{}, SpanID{}, false
+	}
+	if _, err := hex.Decode(parent[:], []byte(span)); err != nil {
+		return TraceID{}, SpanID{}, false
+	}
+	if !traceID.IsValid() || !parent.IsValid() {
+		return TraceID{}, SpanID{}, false
+	}
+	return traceID, parent, true
+}
+
+// deterministicTraceID derives a trace ID from VXID and start time of the root
+// transaction. VXIDs are reused after Varnish restarts, the start time makes
+// collisions unlikely.
+func deterministicTraceID(vxid vslparser.VXID, start time.Time) TraceID {
+	var buf [12]byte
+	binary.BigEndian.PutUint32(buf[:4], uint32(vxid))
+	binary.BigEndian.PutUint64(buf[4:], uint64(start.UnixNano()))
+
+	h := fnv.New128a()
+	h.Write(buf[:])
+
+	var id TraceID
+	copy(id[:], h.Sum(nil))
+	return id
+}
+
+// deterministicSpanID derives a span ID from the trace ID and VXID of the
+// transaction.
+func deterministicSpanID(traceID TraceID, vxid vslparser.VXID) SpanID {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], uint32(vxid))
+
+	h := fnv.New64a()
+	h.Write(traceID[:])
+	h.Write(buf[:])
+
+	var id SpanID
+	copy(id[:], h.Sum(nil))
+	return id
+}
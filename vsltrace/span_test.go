@@ -0,0 +1,136 @@
+package vsltrace_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	vsl "github.com/Showmax/vslparser"
+	"github.com/Showmax/vslparser/internal/testlog"
+	"github.com/Showmax/vslparser/vsltag"
+	"github.com/Showmax/vslparser/vsltrace"
+)
+
+func TestSpans(t *testing.T) {
+	spans, err := vsltrace.Spans(testlog.RequestGroups(t)[0])
+	if err != nil {
+		t.Fatalf("Spans() failed: %v", err)
+	}
+	if len(spans) != 2 {
+		t.Fatalf("Spans() returned %d spans, want 2", len(spans))
+	}
+
+	req, bereq := spans[0], spans[1]
+	if req.Name != "GET" || req.Kind != vsltrace.SpanKindServer || !req.Error {
+		t.Errorf("unexpected request span %+v", req)
+	}
+	if req.ParentSpanID.IsValid() {
+		t.Errorf("request span has parent %v", req.ParentSpanID)
+	}
+	if got, want := req.End.Sub(req.Start), 666*time.Microsecond; got != want {
+		t.Errorf("request span duration = %v, want %v", got, want)
+	}
+	wantAttrs := []vsltrace.Attribute{
+		{Key: "varnish.vxid", Value: int64(2)},
+		{Key: "varnish.reason", Value: "rxreq"},
+		{Key: "http.request.method", Value: "GET"},
+		{Key: "url.path", Value: "/"},
+		{Key: "http.response.status_code", Value: int64(503)},
+		{Key: "client.address", Value: "127.0.0.1"},
+		{Key: "varnish.cache.outcome", Value: "miss"},
+	}
+	if !reflect.DeepEqual(req.Attributes, wantAttrs) {
+		t.Errorf("request span attributes = %v, want %v", req.Attributes, wantAttrs)
+	}
+
+	if bereq.Kind != vsltrace.SpanKindClient || bereq.ParentSpanID != req.SpanID || bereq.TraceID != req.TraceID {
+		t.Errorf("unexpected backend request span %+v", bereq)
+	}
+	if !bereq.Error || bereq.StatusMessage != "backend default: fail errno 111 (Connection refused)" {
+		t.Errorf("backend request span status = %v %q", bereq.Error, bereq.StatusMessage)
+	}
+	if v, _ := bereq.Attribute("varnish.reason"); v != "fetch" {
+		t.Errorf("backend request span reason = %v, want fetch", v)
+	}
+
+	// IDs are deterministic.
+	again, err := vsltrace.Spans(testlog.RequestGroups(t)[0])
+	if err != nil || again[0].TraceID != req.TraceID || again[1].SpanID != bereq.SpanID {
+		t.Errorf("IDs of repeated conversion differ")
+	}
+}
+
+func TestSpans_MalformedTimestamp(t *testing.T) {
+	entries := []vsl.Entry{
+		{Level: 1, Kind: vsl.KindRequest, VXID: 2, Tags: []vsl.Tag{
+			{Key: "Begin", Value: "req 1 rxreq"},
+			{Key: "Timestamp", Value: "Start: 1646693544.293284"},
+			{Key: "End", Value: ""},
+		}},
+	}
+	if _, err := vsltrace.Spans(entries); !errors.Is(err, vsltag.ErrMissingField) {
+		t.Errorf("Spans() error = %v, want ErrMissingField", err)
+	}
+}
+
+func TestSpans_Traceparent(t *testing.T) {
+	entries := []vsl.Entry{
+		{Level: 1, Kind: vsl.KindSession, VXID: 1, Tags: []vsl.Tag{
+			{Key: "Begin", Value: "sess 0 HTTP/1"},
+			{Key: "SessOpen", Value: "127.0.0.1 37976 a0 127.0.0.1 6081 1646693481.899000 24"},
+			{Key: "Link", Value: "req 2 rxreq"},
+			{Key: "SessClose", Value: "REM_CLOSE 0.010"},
+			{Key: "End", Value: ""},
+		}},
+		{Level: 2, Kind: vsl.KindRequest, VXID: 2, Tags: []vsl.Tag{
+			{Key: "Begin", Value: "req 1 rxreq"},
+			{Key: "Timestamp", Value: "Start: 1646693481.899847 0.000000 0.000000"},
+			{Key: "ReqHeader", Value: "traceparent: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			{Key: "Timestamp", Value: "Resp: 1646693481.900513 0.000665 0.000666"},
+			{Key: "End", Value: ""},
+		}},
+	}
+
+	spans, err := vsltrace.Spans(entries)
+	if err != nil {
+		t.Fatalf("Spans() failed: %v", err)
+	}
+	if len(spans) != 2 {
+		t.Fatalf("Spans() returned %d spans, want 2", len(spans))
+	}
+	sess, req := spans[0], spans[1]
+	if sess.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sess.ParentSpanID.String() != "00f067aa0ba902b7" {
+		t.Errorf("session span does not continue the trace: %v %v", sess.TraceID, sess.ParentSpanID)
+	}
+	if req.TraceID != sess.TraceID || req.ParentSpanID != sess.SpanID {
+		t.Errorf("request span is not a child of the session span")
+	}
+	if sess.Name != "session" || sess.End.Sub(sess.Start) != 10*time.Millisecond {
+		t.Errorf("unexpected session span %+v", sess)
+	}
+	if req.Name != "HTTP" {
+		t.Errorf("request span name = %q, want HTTP", req.Name)
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		value string
+		ok    bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01", false},
+	}
+	for _, tt := range tests {
+		if _, _, ok := vsltrace.ParseTraceparent(tt.value); ok != tt.ok {
+			t.Errorf("ParseTraceparent(%q) ok = %v, want %v", tt.value, ok, tt.ok)
+		}
+	}
+}